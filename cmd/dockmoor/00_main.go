@@ -10,7 +10,16 @@ import (
 	"strings"
 
 	"github.com/MeneDev/dockmoor/dockfmt"
+	_ "github.com/MeneDev/dockmoor/dockfmt/bake"
+	_ "github.com/MeneDev/dockmoor/dockfmt/circleci"
+	_ "github.com/MeneDev/dockmoor/dockfmt/compose"
 	_ "github.com/MeneDev/dockmoor/dockfmt/dockerfile"
+	_ "github.com/MeneDev/dockmoor/dockfmt/github"
+	_ "github.com/MeneDev/dockmoor/dockfmt/gitlab"
+	_ "github.com/MeneDev/dockmoor/dockfmt/helm"
+	_ "github.com/MeneDev/dockmoor/dockfmt/kubernetes"
+	_ "github.com/MeneDev/dockmoor/dockfmt/kustomize"
+	_ "github.com/MeneDev/dockmoor/dockfmt/travis"
 	"github.com/MeneDev/dockmoor/dockmoor"
 	"github.com/jessevdk/go-flags"
 	"github.com/sirupsen/logrus"
@@ -125,6 +134,10 @@ func main() {
 		log.Errorf("Could not add pin command: %s", err)
 	}
 
+	if _, err := addUpdateCommand(mainOptions, AddCommand); err != nil {
+		log.Errorf("Could not add update command: %s", err)
+	}
+
 	exitCode := doMain(mainOptions)
 	osExit(exitCode)
 }
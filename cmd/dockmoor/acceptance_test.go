@@ -229,6 +229,380 @@ func TestExitCodeIsZeroForListLatestAndDockerfile(t *testing.T) {
 	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
 }
 
+func TestListDockerfileFlagImages(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "Dockerfile")
+	dockerfile :=
+		`FROM golang:1.21 AS build
+RUN --mount=type=cache,target=/root/.cache go build
+
+FROM scratch
+COPY --from=build /app /app
+COPY --from=alpine:3.18 /etc/ssl /etc/ssl`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(dockerfile), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "golang:1.21\nscratch\nalpine:3.18\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListDockerfileWithSyntaxAndHeredoc(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "Dockerfile")
+	dockerfile :=
+		`# syntax=docker/dockerfile:1
+FROM alpine:3.18
+RUN <<SCRIPT
+FROM nginx
+SCRIPT`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(dockerfile), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "docker/dockerfile:1\nalpine:3.18\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListDockerfileArgImagesWithBuildArg(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "Dockerfile")
+	dockerfile :=
+		`ARG BASE=node:20
+ARG VERSION=3.18
+FROM ${BASE}
+FROM alpine:${VERSION}`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(dockerfile), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "node:20\nalpine:3.18\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --build-arg BASE=node:18 --build-arg=VERSION=3.19 {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "node:18\nalpine:3.19\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestStageReferencesAreNotImages(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "Dockerfile")
+	dockerfile :=
+		`FROM golang:1.21@sha256:2c4269d573d9fc6e9e95d4ad1c5b5ba5ac2b2b61d9ba0d2c2c5a5f5d0a7b1e0f AS builder
+FROM builder AS test
+FROM alpine:3.18@sha256:2c4269d573d9fc6e9e95d4ad1c5b5ba5ac2b2b61d9ba0d2c2c5a5f5d0a7b1e0f
+COPY --from=builder /app /app`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(dockerfile), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor contains --unpinned {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Empty(t, stdout)
+	assert.Equal(t, ExitNotFound, code, "Exits with code ExitNotFound")
+
+	stdout, code = shell(t, `dockmoor list --stage=builder {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "golang:1.21@sha256:2c4269d573d9fc6e9e95d4ad1c5b5ba5ac2b2b61d9ba0d2c2c5a5f5d0a7b1e0f\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestStageMatchesArgImagesByTheFirstFrom(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "Dockerfile")
+	dockerfile :=
+		`ARG BASE=golang:1.21
+ARG RUNTIME=alpine:3.18
+FROM ${BASE} AS builder
+FROM $RUNTIME AS final
+FROM $BASE AS test`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(dockerfile), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list --stage=builder {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "golang:1.21\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --stage=final {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "alpine:3.18\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --stage=test {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Empty(t, stdout)
+	assert.Equal(t, ExitNotFound, code, "Exits with code ExitNotFound")
+}
+
+func TestListComposeFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "docker-compose.yml")
+	compose :=
+		`version: "3"
+services:
+  web:
+    image: nginx:1.15
+  db:
+    image: postgres
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(compose), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Compose}}`, struct {
+		Compose string
+	}{tmpfn})
+
+	assert.Equal(t, "nginx:1.15\npostgres\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListComposeFileExtendingOtherFile(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"common.yml":         "services:\n  worker:\n    image: alpine:3.9\n",
+		"docker-compose.yml": "services:\n  db:\n    image: postgres:11\n  worker:\n    extends:\n      file: common.yml\n      service: worker\n",
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, code := shell(t, `dockmoor list {{.Compose}}`, struct {
+		Compose string
+	}{filepath.Join(dir, "docker-compose.yml")})
+
+	assert.Equal(t, "postgres:11\nalpine:3.9\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	// the extended image is used by both files
+	stdout, code = shell(t, `dockmoor list {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "alpine:3.9\npostgres:11\nalpine:3.9\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListGitlabCiFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, ".gitlab-ci.yml")
+	gitlabCi :=
+		`image: golang:1.12
+services:
+  - postgres:11
+build:
+  image:
+    name: alpine:3.9
+  script: make
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(gitlabCi), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.GitlabCi}}`, struct {
+		GitlabCi string
+	}{tmpfn})
+
+	assert.Equal(t, "golang:1.12\npostgres:11\nalpine:3.9\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListTravisCiFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, ".travis.yml")
+	travisCi :=
+		`language: go
+services:
+  - docker
+before_install:
+  - docker pull postgres:11
+script:
+  - docker run --rm -v $PWD:/src golang:1.12 make
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(travisCi), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.TravisCi}}`, struct {
+		TravisCi string
+	}{tmpfn})
+
+	assert.Equal(t, "postgres:11\ngolang:1.12\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListKubernetesManifest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "deployment.yaml")
+	manifest :=
+		`apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.15
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(manifest), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Manifest}}`, struct {
+		Manifest string
+	}{tmpfn})
+
+	assert.Equal(t, "nginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListKustomization(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "kustomization.yaml")
+	kustomization :=
+		`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - deployment.yaml
+images:
+  - name: nginx
+    newName: example/nginx
+    newTag: "1.15"
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(kustomization), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Kustomization}}`, struct {
+		Kustomization string
+	}{tmpfn})
+
+	assert.Equal(t, "example/nginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListHelmValues(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "values.yaml")
+	values :=
+		`replicaCount: 1
+image:
+  registry: docker.io
+  repository: bitnami/nginx
+  tag: "1.15"
+  pullPolicy: IfNotPresent
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(values), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Values}}`, struct {
+		Values string
+	}{tmpfn})
+
+	assert.Equal(t, "docker.io/bitnami/nginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListBakeFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	hcl := filepath.Join(dir, "docker-bake.hcl")
+	bake :=
+		`target "app" {
+  contexts = {
+    base = "docker-image://alpine:3.18"
+  }
+  cache-from = ["type=registry,ref=user/app:cache"]
+  args = {
+    BASE_IMAGE = "golang:1.21"
+  }
+}
+`
+	if err := ioutil.WriteFile(hcl, []byte(bake), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	json := filepath.Join(dir, "docker-bake.json")
+	bake = `{"target": {"app": {"contexts": {"base": "docker-image://nginx:1.15"}}}}`
+	if err := ioutil.WriteFile(json, []byte(bake), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Hcl}} {{.Json}}`, struct {
+		Hcl  string
+		Json string
+	}{hcl, json})
+
+	assert.Equal(t, "alpine:3.18\nuser/app:cache\ngolang:1.21\nnginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
 func TestExitCodeIs_ExitInvalidFormat_ForInvalidDockerfile(t *testing.T) {
 	dir, _ := ioutil.TempDir("", "dockmoor")
 	defer os.RemoveAll(dir)
@@ -287,3 +661,246 @@ func shell(t *testing.T, argsLine string, values interface{}) (stdout string, ex
 	assert.True(t, exitCodeSet, "Expected exitCode to be set (no call to osExit)")
 	return
 }
+
+func dockerfileTree(t *testing.T, files map[string]string) (dir string) {
+	dir, err := ioutil.TempDir("", "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestContainsMatchesInAnyOfMultipleFiles(t *testing.T) {
+	df1 := dockerfile(`FROM nginx:1`)
+	defer os.Remove(df1)
+	df2 := dockerfile(`FROM nginx`)
+	defer os.Remove(df2)
+
+	os.Args = []string{"exe", "contains", "--latest", df1, df2}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+}
+
+func TestContainsNoMatchInMultipleFiles(t *testing.T) {
+	df1 := dockerfile(`FROM nginx:1`)
+	defer os.Remove(df1)
+	df2 := dockerfile(`FROM nginx:2`)
+	defer os.Remove(df2)
+
+	os.Args = []string{"exe", "contains", "--latest", df1, df2}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitNotFound, exitCode)
+}
+
+func TestContainsMatchesAmongInvalidFiles(t *testing.T) {
+	df1 := dockerfile(`FROM nginx`)
+	defer os.Remove(df1)
+	df2 := dockerfile(`invalid`)
+	defer os.Remove(df2)
+
+	os.Args = []string{"exe", "contains", df2, df1}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Contains(t, mainOptions.stdout.(*bytes.Buffer).String(), "Could not process "+df2)
+}
+
+func TestContainsReportsInvalidFileAmongMultipleFiles(t *testing.T) {
+	df1 := dockerfile(`FROM nginx:1.15`)
+	defer os.Remove(df1)
+	df2 := dockerfile(`invalid`)
+	defer os.Remove(df2)
+
+	os.Args = []string{"exe", "contains", "--latest", df1, df2}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidFormat, exitCode)
+}
+
+func TestContainsReportsMostSevereErrorAmongMultipleFiles(t *testing.T) {
+	df1 := dockerfile(`invalid`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "contains", df1, df1 + ".missing"}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitCouldNotOpenFile, exitCode)
+}
+
+func TestListWalksDirectoriesAndSkipsUnknownFormats(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"Dockerfile":             "FROM nginx:1",
+		"README.md":              "# not a Dockerfile",
+		"sub/Dockerfile":         "FROM alpine:3.8",
+		".hidden/Dockerfile":     "FROM hidden",
+		"sub/docker-compose.yml": "services:\n  app:\n    image: redis:5\n",
+	})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", dir}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "nginx:1\nalpine:3.8\nredis:5\n", mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestListWalksDirectoriesAndSkipsAmbiguousFormats(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"Dockerfile": "FROM nginx:1",
+		"ci.yml":     "services:\n  app:\n    image: redis:5\njobs:\n  build:\n    runs-on: ubuntu-latest\n    container: golang:1.12\n",
+	})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", dir}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	stdout := mainOptions.stdout.(*bytes.Buffer).String()
+	assert.Contains(t, stdout, "nginx:1\n")
+	assert.Contains(t, stdout, "the format is ambiguous")
+	assert.NotContains(t, stdout, "redis:5")
+}
+
+func TestListWalksGithubWorkflows(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"Dockerfile": "FROM nginx:1",
+		".github/workflows/ci.yml": `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: golang:1.12
+    steps:
+      - uses: docker://alpine:3.8
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", dir}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "golang:1.12\nalpine:3.8\nnginx:1\n", mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestListWalksCircleciConfig(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"Dockerfile": "FROM nginx:1",
+		".circleci/config.yml": `version: 2
+jobs:
+  build:
+    docker:
+      - image: golang:1.12
+      - image: postgres:11
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", dir}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "golang:1.12\npostgres:11\nnginx:1\n", mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestListWithFormatOnlyProcessesThatFormat(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"Containerfile": "FROM alpine:3.8",
+		"docker-compose.yml": `services:
+  web:
+    image: nginx:1
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", "--format", "dockerfile", dir}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "alpine:3.8\n", mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestListWithUnknownFormatIsInvalid(t *testing.T) {
+	df1 := dockerfile(`FROM nginx`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "list", "--format", "unknown", df1}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+}
+
+func TestListWithFormatFailsForFileOfOtherFormat(t *testing.T) {
+	df1 := dockerfile(`FROM nginx`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "list", "--format", "docker-compose", df1}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidFormat, exitCode)
+}
+
+func TestListExpandsGlobPatterns(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{
+		"a/Dockerfile": "FROM nginx:1",
+		"b/Dockerfile": "FROM alpine:3.8",
+		"b/other":      "FROM scratch",
+	})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", filepath.Join(dir, "*", "Dockerfile")}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "nginx:1\nalpine:3.8\n", mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestListGlobWithoutMatchesCouldNotOpenFile(t *testing.T) {
+	dir := dockerfileTree(t, map[string]string{})
+	defer os.RemoveAll(dir)
+
+	os.Args = []string{"exe", "list", filepath.Join(dir, "*.Dockerfile")}
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitCouldNotOpenFile, exitCode)
+}
+
+func TestPinOutputWithMultipleFilesIsInvalid(t *testing.T) {
+	df1 := dockerfile(`FROM nginx`)
+	defer os.Remove(df1)
+	df2 := dockerfile(`FROM nginx`)
+	defer os.Remove(df2)
+
+	os.Args = []string{"exe", "pin", "-o", df1, df1, df2}
+	mainOptions := mainOptionsACNew(addPinCommand)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, mainOptions.stdout.(*bytes.Buffer).String(), "--output can only be used with a single input file")
+}
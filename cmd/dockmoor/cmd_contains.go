@@ -12,16 +12,36 @@ import (
 
 type containsOptions struct {
 	MatchingOptions
-	matches bool
+
+	FormatOptions FormatOptions `group:"Format Options" description:"Control how the input is evaluated"`
+
+	ResolverOptions struct {
+		Resolver string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references, only used by --outdated" choice:"dockerd" choice:"registry" default:"dockerd"`
+	} `group:"Resolver Options" description:"Control how the image references are resolved"`
+
+	resolverFactory func(name string) dockref.Resolver
+	matches         bool
 }
 
 func containsOptionsNew(mainOptions *mainOptions) *containsOptions {
-	return &containsOptions{
+	co := &containsOptions{
 		MatchingOptions: MatchingOptions{
 			mainOpts: mainOptions,
 		},
 		matches: false,
 	}
+
+	co.ResolverOptions.Resolver = "dockerd"
+	co.resolverFactory = defaultResolverFactory
+	co.resolverProvider = co.Resolver
+	co.buildArgsProvider = co.FormatOptions.buildArgs
+	co.formatNameProvider = co.FormatOptions.formatName
+
+	return co
+}
+
+func (co *containsOptions) Resolver() dockref.Resolver {
+	return co.resolverFactory(co.ResolverOptions.Resolver)
 }
 
 func addContainsCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
@@ -51,29 +71,24 @@ func (co *containsOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode
 		return ExitPredicateInvalid, err
 	}
 
-	err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) error {
-		errFormat := mopts.WithFormatProcessorDo(inputReader, func(processor dockfmt.FormatProcessor) error {
+	exitCode, err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) (bool, error) {
+		co.matches = false
+		errFormat := mopts.WithFormatProcessorDo(inputPath, inputReader, func(processor dockfmt.FormatProcessor) error {
 			return co.applyFormatProcessor(predicate, processor)
 		})
-		return errFormat
+		return co.matches, errFormat
 	})
 
-	if errExitCode, ok := exitCodeFromError(err); ok {
-		return errExitCode, err
-	}
-
-	if co.matches {
-		exitCode = ExitSuccess
-	} else {
-		exitCode = ExitNotFound
+	if errPredicate := dockproc.PredicateErr(predicate); errPredicate != nil {
+		return ExitUnknownError, errPredicate
 	}
 
 	return exitCode, err
 }
 
 func (co *containsOptions) applyFormatProcessor(predicate dockproc.Predicate, processor dockfmt.FormatProcessor) error {
-	return processor.Process(func(r dockref.Reference) (dockref.Reference, error) {
-		if predicate.Matches(r) {
+	return processor.ProcessLocated(func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		if dockproc.MatchesLocated(predicate, r, location) {
 			co.matches = true
 		}
 		return r, nil
@@ -11,6 +11,7 @@ import (
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
 	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -83,10 +84,10 @@ func TestInvalidDockerfileWithContains(t *testing.T) {
 		mainOpts: mainOptions.mainOptions,
 	}
 
-	mo.Positional.InputFile = flags.Filename(NotADockerfile)
+	mo.Positional.InputFiles = []flags.Filename{flags.Filename(NotADockerfile)}
 
 	// when
-	err := mo.WithFormatProcessorDo(nil, func(processor dockfmt.FormatProcessor) error {
+	err := mo.WithFormatProcessorDo(NotADockerfile, makeReadCloser("not a dockerfile"), func(processor dockfmt.FormatProcessor) error {
 		return nil
 	})
 
@@ -109,7 +110,7 @@ func TestReportInvalidPredicateWithContains(t *testing.T) {
 	format.OnName().Return("mock")
 	format.OnValidateInput(mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	expected := errors.New("process Error")
-	format.OnProcess(mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expected)
+	format.OnProcess(mock.Anything, mock.Anything, mock.Anything).Return(expected)
 
 	formatProvider.OnFormats().Return([]dockfmt.Format{format})
 
@@ -119,11 +120,11 @@ func TestReportInvalidPredicateWithContains(t *testing.T) {
 		mainOpts: mainOptions.mainOptions,
 	}
 
-	mopts.Positional.InputFile = flags.Filename(NotADockerfile)
+	mopts.Positional.InputFiles = []flags.Filename{flags.Filename(NotADockerfile)}
 
 	// when
-	err := mopts.WithInputDo(func(filePathInput string, fpInput io.Reader) error {
-		return mopts.WithFormatProcessorDo(fpInput, func(processor dockfmt.FormatProcessor) error {
+	_, err := mopts.WithInputDo(func(filePathInput string, fpInput io.Reader) (bool, error) {
+		return false, mopts.WithFormatProcessorDo(filePathInput, fpInput, func(processor dockfmt.FormatProcessor) error {
 			return processor.Process(func(r dockref.Reference) (dockref.Reference, error) {
 				return r, nil
 			})
@@ -137,7 +138,7 @@ func TestFilenameRequiredWithContains(t *testing.T) {
 	_, _, exitCode, stdout := testMain([]string{"contains"}, addContainsCommand)
 	assert.NotEqual(t, 0, exitCode)
 	assert.Contains(t, stdout.String(), "level=error")
-	assert.Contains(t, stdout.String(), "the required argument `InputFile` was not provided")
+	assert.Contains(t, stdout.String(), "the required argument `InputFile (at least 1 argument)` was not provided")
 }
 
 func TestContainsCallsFindExecuteWithContains(t *testing.T) {
@@ -238,3 +239,23 @@ func equalsAnyString(needle string, values ...string) bool {
 
 	return false
 }
+
+func TestContainsOutdatedFailsWhenTheResolverFails(t *testing.T) {
+	df := dockerfile("FROM nginx:1.14\nFROM alpine:3.8")
+	defer os.Remove(df)
+
+	rslvr := dockreftst.MockResolverNew()
+	rslvr.OnFindAllTags(mock.Anything).Return([]dockref.Reference(nil), errors.New("registry unavailable"))
+
+	os.Args = []string{"exe", "contains", "--outdated", df}
+	mainOptions := mainOptionsACNew(func(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+		co := containsOptionsNew(mainOptions)
+		co.resolverFactory = func(name string) dockref.Resolver {
+			return rslvr
+		}
+		return adder(mainOptions, "contains", "", "", co)
+	})
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitUnknownError, exitCode)
+}
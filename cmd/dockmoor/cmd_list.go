@@ -2,7 +2,6 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"io"
 
 	"github.com/MeneDev/dockmoor/dockfmt"
@@ -13,16 +12,43 @@ import (
 
 type listOptions struct {
 	MatchingOptions
-	matches bool
+
+	FormatOptions FormatOptions `group:"Format Options" description:"Control how the input is evaluated"`
+
+	ResolverOptions struct {
+		Resolver string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references, only used by --outdated" choice:"dockerd" choice:"registry" default:"dockerd"`
+	} `group:"Resolver Options" description:"Control how the image references are resolved"`
+
+	Output struct {
+		OutputFormat string `required:"no" long:"output-format" description:"Format of the listed image references" choice:"text" choice:"json" choice:"csv" choice:"template" default:"text"`
+		Template     string `required:"no" long:"template" description:"Go template for each image reference, used with --output-format=template, e.g. '{{.File}}:{{.Line}} {{.Original}}'"`
+	} `group:"Output parameters" description:"Output parameters"`
+
+	resolverFactory func(name string) dockref.Resolver
+	matches         bool
+	writer          listWriter
 }
 
 func listOptionsNew(mainOptions *mainOptions) *listOptions {
-	return &listOptions{
+	lo := &listOptions{
 		MatchingOptions: MatchingOptions{
 			mainOpts: mainOptions,
 		},
 		matches: false,
 	}
+
+	lo.ResolverOptions.Resolver = "dockerd"
+	lo.Output.OutputFormat = "text"
+	lo.resolverFactory = defaultResolverFactory
+	lo.resolverProvider = lo.Resolver
+	lo.buildArgsProvider = lo.FormatOptions.buildArgs
+	lo.formatNameProvider = lo.FormatOptions.formatName
+
+	return lo
+}
+
+func (lo *listOptions) Resolver() dockref.Resolver {
+	return lo.resolverFactory(lo.ResolverOptions.Resolver)
 }
 
 func addListCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
@@ -52,30 +78,45 @@ func (lo *listOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, er
 		return ExitPredicateInvalid, err
 	}
 
-	err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) error {
-		return mopts.WithFormatProcessorDo(inputReader, func(processor dockfmt.FormatProcessor) error {
+	lo.writer, err = lo.listWriterNew()
+	if err != nil {
+		lo.Log().Errorf("Invalid options: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+
+	exitCode, err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) (bool, error) {
+		lo.matches = false
+		errFormat := mopts.WithFormatProcessorDo(inputPath, inputReader, func(processor dockfmt.FormatProcessor) error {
 			return lo.applyFormatProcessor(predicate, processor)
 		})
+		return lo.matches, errFormat
 	})
 
-	if errExitCode, ok := exitCodeFromError(err); ok {
-		return errExitCode, err
+	if errClose := lo.writer.Close(); errClose != nil {
+		return ExitUnknownError, errClose
 	}
 
-	if lo.matches {
-		exitCode = ExitSuccess
-	} else {
-		exitCode = ExitNotFound
+	if errPredicate := dockproc.PredicateErr(predicate); errPredicate != nil {
+		return ExitUnknownError, errPredicate
 	}
 
 	return exitCode, err
 }
 
 func (lo *listOptions) applyFormatProcessor(predicate dockproc.Predicate, processor dockfmt.FormatProcessor) error {
-	return processor.Process(func(r dockref.Reference) (dockref.Reference, error) {
-		if predicate.Matches(r) {
+	if lo.writer == nil {
+		lo.writer = &textListWriter{writer: lo.Stdout()}
+	}
+
+	formatName := ""
+	if format := processor.Format(); format != nil {
+		formatName = format.Name()
+	}
+
+	return processor.ProcessLocated(func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		if dockproc.MatchesLocated(predicate, r, location) {
 			lo.matches = true
-			_, err := fmt.Fprintf(lo.Stdout(), "%s\n", r.Original())
+			err := lo.writer.Write(listEntryNew(formatName, r, location))
 			return r, err
 		}
 		return r, nil
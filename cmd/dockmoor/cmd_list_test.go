@@ -9,6 +9,9 @@ import (
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
 	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/dockref/resolver"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -39,7 +42,7 @@ func TestFilenameRequiredWithList(t *testing.T) {
 	_, _, exitCode, stdout := testMain([]string{"list"}, addListCommand)
 	assert.NotEqual(t, 0, exitCode)
 	assert.Contains(t, stdout.String(), "level=error")
-	assert.Contains(t, stdout.String(), "the required argument `InputFile` was not provided")
+	assert.Contains(t, stdout.String(), "the required argument `InputFile (at least 1 argument)` was not provided")
 }
 
 func TestListCallsFindExecute(t *testing.T) {
@@ -93,7 +96,30 @@ var _ dockfmt.FormatProcessor = (*FormatProcessorMock)(nil)
 type FormatProcessorMock struct {
 	*mock.Mock
 
-	process func(imageNameProcessor dockfmt.ImageNameProcessor) error
+	process        func(imageNameProcessor dockfmt.ImageNameProcessor) error
+	processLocated func(imageNameProcessor dockfmt.LocatedImageNameProcessor) error
+	format         dockfmt.Format
+}
+
+func (d *FormatProcessorMock) ProcessLocated(imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	if d.processLocated != nil {
+		return d.processLocated(imageNameProcessor)
+	}
+	return d.process(func(r dockref.Reference) (dockref.Reference, error) {
+		return imageNameProcessor(r, dockfmt.Location{})
+	})
+}
+
+func (d *FormatProcessorMock) WithFilename(filename string) dockfmt.FormatProcessor {
+	panic("implement me")
+}
+
+func (d *FormatProcessorMock) WithBuildArgs(buildArgs map[string]string) dockfmt.FormatProcessor {
+	panic("implement me")
+}
+
+func (d *FormatProcessorMock) Format() dockfmt.Format {
+	return d.format
 }
 
 func (d *FormatProcessorMock) WithWriter(writer io.Writer) dockfmt.FormatProcessor {
@@ -147,3 +173,44 @@ func TestListOptions_ExecuteWithExitCode(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, ExitPredicateInvalid, exitCode)
 }
+
+func TestListCommandPrintsOutdated(t *testing.T) {
+	lo := listOptionsTestNew()
+	stdout := lo.MainOptions().Stdout()
+
+	resolver := dockreftst.MockResolverNew()
+	resolver.OnFindAllTags(mock.Anything).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.14.2"),
+		dockref.MustParse("nginx:1.15.6"),
+	}, nil)
+	lo.resolverFactory = func(name string) dockref.Resolver {
+		return resolver
+	}
+	lo.TagPredicates.Outdated = true
+
+	predicate, e := lo.getPredicate()
+	assert.Nil(t, e)
+
+	processorMock := &FormatProcessorMock{}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		for _, original := range []string{"nginx:1.14.2", "nginx:1.15.6", "nginx:latest"} {
+			_, e := imageNameProcessor(dockref.MustParse(original))
+			assert.Nil(t, e)
+		}
+		return nil
+	}
+
+	err := lo.applyFormatProcessor(predicate, processorMock)
+	assert.Nil(t, err)
+	assert.Equal(t, "nginx:1.14.2\n", stdout.String())
+}
+
+func TestListUsesRegistryResolver(t *testing.T) {
+	lo := listOptionsNew(nil)
+	testMain([]string{"list", "--outdated", "--resolver", "registry", "fileName"}, func(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+		lo.mainOpts = mainOptions
+		return adder(mainOptions, "list", "", "", lo)
+	})
+	assert.Equal(t, "registry", lo.ResolverOptions.Resolver)
+	assert.IsType(t, resolver.DockerRegistryResolverNew(), lo.Resolver())
+}
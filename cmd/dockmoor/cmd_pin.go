@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
@@ -12,11 +13,15 @@ import (
 	"github.com/MeneDev/dockmoor/dockref/resolver"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sirupsen/logrus"
 )
 
 type pinOptions struct {
 	MatchingOptions
 
+	FormatOptions FormatOptions `group:"Format Options" description:"Control how the input is evaluated"`
+
 	ReferenceFormat struct {
 		ForceDomain bool `required:"no" long:"force-domain" description:"Includes domain even in well-known references"`
 		NoName      bool `required:"no" long:"no-name" description:"Formats well-known references as digest only"`
@@ -25,16 +30,20 @@ type pinOptions struct {
 	} `group:"Reference format" description:"Control the format of references, defaults are sensible, changes are not recommended"`
 
 	PinOptions struct {
-		Resolver string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references" choice:"dockerd" choice:"registry" default:"dockerd"`
-		TagMode  string `required:"no" long:"tag-mode" description:"Strategy to resolve image references" choice:"unchanged" default:"unchanged"`
+		Resolver       string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references" choice:"dockerd" choice:"registry" default:"dockerd"`
+		TagMode        string `required:"no" long:"tag-mode" description:"Strategy to choose the tag of pinned image references" choice:"unchanged" choice:"most-precise-version" default:"unchanged"`
+		PlatformDigest string `required:"no" long:"platform-digest" description:"Digest used for images with a platform, e.g. FROM --platform=linux/arm64: the manifest of the platform or the manifest list" choice:"manifest" choice:"list" default:"manifest"`
 	} `group:"Pin Options" description:"Control how the image references are resolved"`
 
 	Output struct {
 		OutputFile flags.Filename `required:"no" short:"o" long:"output" description:"Output file to write to. If empty, input file will be used."`
+		DryRun     bool           `required:"no" long:"dry-run" description:"Don't write any file, print a unified diff of the changes instead"`
+		Check      bool           `required:"no" long:"check" description:"Don't write any file, exit with a non-zero exit code when pinning would change a file"`
 	} `group:"Output parameters" description:"Output parameters"`
 
 	resolverFactory func(name string) dockref.Resolver
 	matches         bool
+	changes         bool
 }
 
 func (po *pinOptions) Execute(args []string) error {
@@ -55,26 +64,73 @@ func (po *pinOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err
 		return ExitPredicateInvalid, err
 	}
 
-	buffer := bytes.NewBuffer(nil)
+	if po.Output.OutputFile != "" && !mopts.isSingleInput() {
+		err = errors.New("--output can only be used with a single input file")
+		po.Log().Errorf("Invalid options: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+
+	exitCode, err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) (bool, error) {
+		po.matches = false
+		original, errRead := ioutil.ReadAll(inputReader)
+		if errRead != nil {
+			return false, errRead
+		}
+
+		buffer := bytes.NewBuffer(nil)
 
-	err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) error {
-		errFormat := mopts.WithFormatProcessorDo(inputReader, func(processor dockfmt.FormatProcessor) error {
+		errFormat := mopts.WithFormatProcessorDo(inputPath, bytes.NewReader(original), func(processor dockfmt.FormatProcessor) error {
 			processor = processor.WithWriter(buffer)
 			return po.applyFormatProcessor(predicate, processor)
 		})
 
 		if errFormat != nil {
-			exitCode = ExitInvalidFormat
-			return errFormat
+			return false, errFormat
 		}
-		return nil
+
+		return po.matches, po.writeResult(inputPath, original, buffer.Bytes())
 	})
 
-	if errExitCode, ok := exitCodeFromError(err); ok {
-		return errExitCode, err
+	if errPredicate := dockproc.PredicateErr(predicate); errPredicate != nil {
+		return ExitUnknownError, errPredicate
+	}
+
+	if po.Output.Check && (exitCode == ExitSuccess || exitCode == ExitNotFound) {
+		if po.changes {
+			return ExitChangesPending, err
+		}
+		return ExitSuccess, err
+	}
+
+	return exitCode, err
+}
+
+func (po *pinOptions) writeResult(inputPath string, original []byte, pinned []byte) error {
+	changed := !bytes.Equal(original, pinned)
+	if changed {
+		po.changes = true
 	}
 
-	err = po.WithOutputDo(func(outputPath string) error {
+	if po.Output.Check && changed {
+		po.Log().Warnf("Pinning would change %s", inputPath)
+	}
+
+	if po.Output.DryRun {
+		if !changed {
+			return nil
+		}
+		return unifiedDiff(po.Stdout(), inputPath, original, pinned)
+	}
+
+	if po.Output.Check {
+		return nil
+	}
+
+	return po.WithOutputDo(inputPath, func(outputPath string) error {
+		if !changed && outputPath == inputPath {
+			return nil
+		}
+
 		mode := os.FileMode(0660)
 
 		info, e := os.Stat(outputPath)
@@ -82,22 +138,38 @@ func (po *pinOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err
 			mode = info.Mode()
 		}
 
-		errWriteFile := ioutil.WriteFile(outputPath, buffer.Bytes(), mode)
+		errWriteFile := ioutil.WriteFile(outputPath, pinned, mode)
 		return errWriteFile
 	})
+}
 
-	if po.matches {
-		exitCode = ExitSuccess
-	} else {
-		exitCode = ExitNotFound
+func unifiedDiff(writer io.Writer, path string, original []byte, changed []byte) error {
+	diff := difflib.UnifiedDiff{
+		A:        splitLines(original),
+		B:        splitLines(changed),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
 	}
 
-	return exitCode, err
+	return difflib.WriteUnifiedDiff(writer, diff)
+}
+
+// splitLines splits content after each newline, unlike difflib.SplitLines no empty line is appended.
+// A missing newline at the end of content is added so the diff stays readable.
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	last := len(lines) - 1
+	if lines[last] == "" {
+		return lines[:last]
+	}
+	lines[last] += "\n"
+	return lines
 }
 
 func (po *pinOptions) applyFormatProcessor(predicate dockproc.Predicate, processor dockfmt.FormatProcessor) error {
-	return processor.Process(func(original dockref.Reference) (dockref.Reference, error) {
-		if predicate.Matches(original) {
+	return processor.ProcessLocated(func(original dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		if dockproc.MatchesLocated(predicate, original, location) {
 			po.matches = true
 			repo := po.Resolver()
 
@@ -106,34 +178,87 @@ func (po *pinOptions) applyFormatProcessor(predicate dockproc.Predicate, process
 				return nil, e
 			}
 
+			resolve, e := platformResolve(po.Log(), repo, location, po.PinOptions.PlatformDigest)
+			if e != nil {
+				return nil, e
+			}
+
+			var resolved dockref.Reference
 			switch mode {
 			case dockref.ResolveModeUnchanged:
-				resolved, err := repo.Resolve(original)
-				if err != nil {
-					po.Log().WithField("error", err.Error()).Errorf("Could not resolve %s", original.Original())
-					return nil, err
+				resolved, e = resolve(original)
+				if e != nil {
+					po.Log().WithField("location", location.String()).WithField("error", e.Error()).Errorf("Could not resolve %s", original.Original())
+					return nil, e
 				}
 
-				format, err := po.RefFormat()
-				if err != nil {
-					return nil, err
-				}
-
-				formatted, err := resolved.WithRequestedFormat(format)
-				if err != nil {
-					return nil, err
+			case dockref.ResolveModeMostPreciseVersion:
+				resolved, e = po.mostPreciseVersion(repo, resolve, original)
+				if e != nil {
+					po.Log().WithField("location", location.String()).WithField("error", e.Error()).Errorf("Could not find most precise version of %s", original.Original())
+					return nil, e
 				}
+			}
 
-				return formatted, nil
+			format, err := po.RefFormat()
+			if err != nil {
+				return nil, err
+			}
 
-			case dockref.ResolveModeMostPreciseVersion:
-				return nil, errors.Errorf("VersionMode %s not yet implemented", po.PinOptions.TagMode)
+			formatted, err := resolved.WithRequestedFormat(format)
+			if err != nil {
+				return nil, err
 			}
+
+			return formatted, nil
 		}
 		return original, nil
 	})
 }
 
+// mostPreciseVersion resolves original and replaces its tag with the most precise tag referencing the same image
+func (po *pinOptions) mostPreciseVersion(repo dockref.Resolver, resolve resolveFunc, original dockref.Reference) (dockref.Reference, error) {
+	resolved, err := resolve(original)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := repo.FindAllTags(original)
+	if err != nil {
+		return nil, err
+	}
+
+	return dockref.MostPreciseTag(resolved, tags, resolve, po.Log())
+}
+
+type resolveFunc func(reference dockref.Reference) (dockref.Reference, error)
+
+// platformResolve returns the function to resolve the image at location.
+// Images with a platform are resolved to the manifest of the platform, unless platformDigest is "list".
+// Resolvers that cannot resolve platforms fail, they would write the digest of the manifest list instead.
+func platformResolve(log logrus.FieldLogger, repo dockref.Resolver, location dockfmt.Location, platformDigest string) (resolveFunc, error) {
+	if location.Platform == "" || platformDigest == "list" {
+		return repo.Resolve, nil
+	}
+
+	platform, err := dockref.ParsePlatform(location.Platform)
+	if err != nil {
+		log.WithField("location", location.String()).Errorf("Invalid platform %s", location.Platform)
+		return nil, err
+	}
+
+	platformResolver, ok := repo.(dockref.PlatformResolver)
+	if !ok {
+		err = errors.Errorf("The resolver cannot resolve the manifest of platform %s, use --resolver=registry or --platform-digest=list", platform.String())
+		log.WithField("location", location.String()).Errorf("Could not resolve platform %s: %s", platform.String(), err.Error())
+		return nil, err
+	}
+
+	return func(reference dockref.Reference) (dockref.Reference, error) {
+		return platformResolver.ResolvePlatform(reference, platform)
+	}, nil
+}
+
 func tagMode(modeString string) (dockref.ResolveMode, error) {
 	switch modeString {
 	case "unchanged":
@@ -158,7 +283,11 @@ func pinOptionsNew(mainOptions *mainOptions) *pinOptions {
 	}
 
 	po.PinOptions.TagMode = "unchanged"
+	po.PinOptions.PlatformDigest = "manifest"
 	po.resolverFactory = defaultResolverFactory
+	po.resolverProvider = po.Resolver
+	po.buildArgsProvider = po.FormatOptions.buildArgs
+	po.formatNameProvider = po.FormatOptions.formatName
 
 	return &po
 }
@@ -225,11 +354,11 @@ func (po *pinOptions) RefFormat() (dockref.Format, error) {
 	return format, nil
 }
 
-func (po *pinOptions) WithOutputDo(action func(outputPath string) error) error {
+func (po *pinOptions) WithOutputDo(inputPath string, action func(outputPath string) error) error {
 	filename := string(po.Output.OutputFile)
 	if filename != "" {
 		return action(filename)
 	}
 
-	return po.MatchingOptions.WithOutputDo(action)
+	return po.MatchingOptions.WithOutputDo(inputPath, action)
 }
@@ -102,10 +102,10 @@ func TestInvalidDockerfileWithPin(t *testing.T) {
 
 	mainOptions.formatProvider = formatProvider
 
-	po.Positional.InputFile = flags.Filename(NotADockerfile)
+	po.Positional.InputFiles = []flags.Filename{flags.Filename(NotADockerfile)}
 
 	// when
-	err := po.WithFormatProcessorDo(nil, func(processor dockfmt.FormatProcessor) error {
+	err := po.WithFormatProcessorDo(NotADockerfile, makeReadCloser("not a dockerfile"), func(processor dockfmt.FormatProcessor) error {
 		return nil
 	})
 
@@ -202,7 +202,7 @@ func TestPinCommand_applyFormatProcessor_FailsWithInvalidFormattingFlags(t *test
 
 	format := &FormatMock{}
 	format.OnName().Return("Mock")
-	format.OnProcess(mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	format.OnProcess(mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	processorMock := &FormatProcessorMock{}
 
 	po.mockResolver.OnResolve(mock.Anything).
@@ -222,6 +222,7 @@ func TestPinCommand_applyFormatProcessor_FailsWithInvalidFormattingFlags(t *test
 
 func TestPinCommand_FailsWhenErrorInProcess(t *testing.T) {
 	po := pinOptionsTestNew()
+	po.Positional.InputFiles = []flags.Filename{flags.Filename(ADockerfile)}
 	po.ReferenceFormat.NoName = true
 	po.ReferenceFormat.NoTag = true
 	po.ReferenceFormat.NoDigest = true
@@ -230,7 +231,7 @@ func TestPinCommand_FailsWhenErrorInProcess(t *testing.T) {
 	format.OnName().Return("Mock")
 	expected := errors.New("a Process Error")
 
-	format.OnProcess(mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expected)
+	format.OnProcess(mock.Anything, mock.Anything, mock.Anything).Return(expected)
 
 	format.OnValidateInput(mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
@@ -324,104 +325,269 @@ func TestPinCommandPins_unchanged(t *testing.T) {
 }
 
 func TestPinCommandPins_most_precise_version(t *testing.T) {
-	t.SkipNow()
-
-	//po := pinOptionsTestNew()
-	//po.mockResolver.OnFindAllTags(dockref.MustParse("nginx")).
-	//	Return([]dockref.Reference{dockref.MustParse("nginx:tag@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")}, nil)
-	//
-	//po.mockResolver.OnFindAllTags(dockref.MustParse("nginx:latest")).
-	//	Return([]dockref.Reference{
-	//		dockref.MustParse("nginx:1@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
-	//		dockref.MustParse("nginx:1.15@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
-	//		dockref.MustParse("nginx:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
-	//		dockref.MustParse("nginx:latest@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
-	//	}, nil)
-	//
-	//po.mockResolver.OnFindAllTags(dockref.MustParse("menedev/testimagea")).
-	//	Return([]dockref.Reference{
-	//		dockref.MustParse("menedev/testimagea:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
-	//	}, nil)
-	//
-	//processorMock := &FormatProcessorMock{}
-	//
-	//pin := func(refStr, expected string) {
-	//	ran := false
-	//	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
-	//		ref, e := imageNameProcessor(dockref.MustParse(refStr))
-	//		assert.Nil(t, e)
-	//		str := ref.String()
-	//		assert.Equal(t, expected, str)
-	//		ran = true
-	//		return nil
-	//	}
-	//	predicate, e := dockproc.AnyPredicateNew()
-	//	assert.Nil(t, e)
-	//
-	//	po.applyFormatProcessor(predicate, processorMock)
-	//	assert.True(t, ran)
-	//}
-	//
-	//pinNginx := func(expected string) {
-	//	pin("nginx", expected)
-	//}
-	//
-	//t.Run("tag and sha", func(t *testing.T) {
-	//	po.ReferenceFormat.ForceDomain = false
-	//	po.ReferenceFormat.NoName = false
-	//	po.ReferenceFormat.NoTag = false
-	//	po.ReferenceFormat.NoDigest = false
-	//
-	//	pinNginx("nginx:tag@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
-	//})
-	//t.Run("tag only", func(t *testing.T) {
-	//	po.ReferenceFormat.ForceDomain = false
-	//	po.ReferenceFormat.NoName = false
-	//	po.ReferenceFormat.NoTag = false
-	//	po.ReferenceFormat.NoDigest = true
-	//
-	//	pinNginx("nginx:tag")
-	//})
-	//t.Run("sha and name", func(t *testing.T) {
-	//	po.ReferenceFormat.ForceDomain = false
-	//	po.ReferenceFormat.NoName = false
-	//	po.ReferenceFormat.NoTag = true
-	//	po.ReferenceFormat.NoDigest = false
-	//
-	//	pinNginx("nginx@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
-	//})
-	//t.Run("sha only", func(t *testing.T) {
-	//	po.ReferenceFormat.ForceDomain = false
-	//	po.ReferenceFormat.NoName = true
-	//	po.ReferenceFormat.NoTag = true
-	//	po.ReferenceFormat.NoDigest = false
-	//
-	//	pinNginx("d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
-	//})
-	//
-	//t.Run("FindAllTags to most precise tag", func(t *testing.T) {
-	//	po.ReferenceFormat.ForceDomain = false
-	//	po.ReferenceFormat.NoName = false
-	//	po.ReferenceFormat.NoTag = false
-	//	po.ReferenceFormat.NoDigest = false
-	//
-	//	pin("nginx:latest", "nginx:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")
-	//})
-	//t.Run("Does not add domain to well-known user image references when ForceDomain = false", func(t *testing.T) {
-	//	po.ReferenceFormat.ForceDomain = false
-	//	po.ReferenceFormat.NoName = false
-	//	po.ReferenceFormat.NoTag = false
-	//	po.ReferenceFormat.NoDigest = false
-	//
-	//	pin("menedev/testimagea", "menedev/testimagea:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")
-	//})
+	po := pinOptionsTestNew()
+	po.PinOptions.TagMode = "most-precise-version"
+
+	po.mockResolver.OnResolve(dockref.MustParse("nginx")).
+		Return(dockref.MustParse("nginx@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"), nil)
+	po.mockResolver.OnFindAllTags(dockref.MustParse("nginx")).
+		Return([]dockref.Reference{
+			dockref.MustParse("nginx:1@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+			dockref.MustParse("nginx:1.15@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+			dockref.MustParse("nginx:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+			dockref.MustParse("nginx:1.14.2@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"),
+			dockref.MustParse("nginx:latest@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+		}, nil)
+
+	po.mockResolver.OnResolve(dockref.MustParse("menedev/testimagea:1")).
+		Return(dockref.MustParse("menedev/testimagea:1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"), nil)
+	po.mockResolver.OnFindAllTags(dockref.MustParse("menedev/testimagea:1")).
+		Return([]dockref.Reference{
+			dockref.MustParse("menedev/testimagea:1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"),
+			dockref.MustParse("menedev/testimagea:1.1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"),
+			dockref.MustParse("menedev/testimagea:1.1.1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"),
+			dockref.MustParse("menedev/testimagea:2.0.0@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+		}, nil)
+
+	processorMock := &FormatProcessorMock{}
+
+	pin := func(refStr, expected string) {
+		ran := false
+		processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+			ref, e := imageNameProcessor(dockref.MustParse(refStr))
+			assert.Nil(t, e)
+			str := ref.String()
+			assert.Equal(t, expected, str)
+			ran = true
+			return nil
+		}
+		predicate, e := dockproc.AnyPredicateNew()
+		assert.Nil(t, e)
+
+		po.applyFormatProcessor(predicate, processorMock)
+		assert.True(t, ran)
+	}
+
+	t.Run("tag and sha", func(t *testing.T) {
+		po.ReferenceFormat.ForceDomain = false
+		po.ReferenceFormat.NoName = false
+		po.ReferenceFormat.NoTag = false
+		po.ReferenceFormat.NoDigest = false
+
+		pin("nginx", "nginx:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")
+	})
+	t.Run("tag only", func(t *testing.T) {
+		po.ReferenceFormat.ForceDomain = false
+		po.ReferenceFormat.NoName = false
+		po.ReferenceFormat.NoTag = false
+		po.ReferenceFormat.NoDigest = true
+
+		pin("nginx", "nginx:1.15.6")
+	})
+	t.Run("Stays within version", func(t *testing.T) {
+		po.ReferenceFormat.ForceDomain = false
+		po.ReferenceFormat.NoName = false
+		po.ReferenceFormat.NoTag = false
+		po.ReferenceFormat.NoDigest = false
+
+		pin("menedev/testimagea:1", "menedev/testimagea:1.1.1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
+	})
+}
+
+func TestPinCommandPins_most_precise_version_reports_errors(t *testing.T) {
+	po := pinOptionsTestNew()
+	po.PinOptions.TagMode = "most-precise-version"
+
+	expected := errors.New("expected")
+	po.mockResolver.OnResolve(dockref.MustParse("nginx")).
+		Return(dockref.MustParse("nginx@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"), nil)
+	po.mockResolver.OnFindAllTags(dockref.MustParse("nginx")).
+		Return([]dockref.Reference(nil), expected)
+
+	processorMock := &FormatProcessorMock{}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		_, e := imageNameProcessor(dockref.MustParse("nginx"))
+		return e
+	}
+	predicate, e := dockproc.AnyPredicateNew()
+	assert.Nil(t, e)
+
+	err := po.applyFormatProcessor(predicate, processorMock)
+	assert.Equal(t, expected, err)
+}
+
+func TestPinCommandPins_platform(t *testing.T) {
+	const listDigest = "sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"
+	const arm64Digest = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+
+	pin := func(po *pinOptionsTest, location dockfmt.Location) (dockref.Reference, error) {
+		var pinned dockref.Reference
+		processorMock := &FormatProcessorMock{}
+		processorMock.processLocated = func(imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+			var e error
+			pinned, e = imageNameProcessor(dockref.MustParse("debian:12"), location)
+			return e
+		}
+		predicate, e := dockproc.AnyPredicateNew()
+		assert.Nil(t, e)
+
+		err := po.applyFormatProcessor(predicate, processorMock)
+		return pinned, err
+	}
+
+	platformResolverPinOptions := func() (*pinOptionsTest, *dockreftst.MockPlatformResolver) {
+		po := pinOptionsTestNew()
+		rslvr := dockreftst.MockPlatformResolverNew()
+		rslvr.OnResolve(dockref.MustParse("debian:12")).
+			Return(dockref.MustParse("debian:12@"+listDigest), nil)
+		rslvr.OnResolvePlatform(dockref.MustParse("debian:12"), dockref.Platform{OS: "linux", Architecture: "arm64"}).
+			Return(dockref.MustParse("debian:12@"+arm64Digest), nil)
+		po.resolverFactory = func(_name string) dockref.Resolver {
+			return rslvr
+		}
+		return po, rslvr
+	}
+
+	t.Run("resolves the manifest of the platform", func(t *testing.T) {
+		po, _ := platformResolverPinOptions()
+		pinned, err := pin(po, dockfmt.Location{Platform: "linux/arm64"})
+		assert.Nil(t, err)
+		assert.Equal(t, "debian:12@"+arm64Digest, pinned.String())
+	})
+	t.Run("resolves the manifest list without platform", func(t *testing.T) {
+		po, _ := platformResolverPinOptions()
+		pinned, err := pin(po, dockfmt.Location{})
+		assert.Nil(t, err)
+		assert.Equal(t, "debian:12@"+listDigest, pinned.String())
+	})
+	t.Run("resolves the manifest list with --platform-digest=list", func(t *testing.T) {
+		po, _ := platformResolverPinOptions()
+		po.PinOptions.PlatformDigest = "list"
+		pinned, err := pin(po, dockfmt.Location{Platform: "linux/arm64"})
+		assert.Nil(t, err)
+		assert.Equal(t, "debian:12@"+listDigest, pinned.String())
+	})
+	t.Run("fails when the resolver cannot resolve platforms", func(t *testing.T) {
+		po := pinOptionsTestNew()
+		po.mockResolver.OnResolve(dockref.MustParse("debian:12")).
+			Return(dockref.MustParse("debian:12@"+listDigest), nil)
+		_, err := pin(po, dockfmt.Location{Platform: "linux/arm64"})
+		assert.Error(t, err)
+	})
+	t.Run("fails with the dockerd resolver", func(t *testing.T) {
+		po := pinOptionsTestNew()
+		po.resolverFactory = defaultResolverFactory
+		_, err := pin(po, dockfmt.Location{Platform: "linux/arm64"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "--resolver=registry")
+	})
+	t.Run("uses the manifest list with the dockerd resolver and --platform-digest=list", func(t *testing.T) {
+		po := pinOptionsTestNew()
+		resolve, err := platformResolve(po.Log(), defaultResolverFactory("dockerd"), dockfmt.Location{Platform: "linux/arm64"}, "list")
+		assert.Nil(t, err)
+		assert.NotNil(t, resolve)
+	})
+	t.Run("fails with invalid platform", func(t *testing.T) {
+		po, _ := platformResolverPinOptions()
+		_, err := pin(po, dockfmt.Location{Platform: "arm64"})
+		assert.Error(t, err)
+	})
+}
+
+func TestPinAutomaticPlatformUsesManifestList(t *testing.T) {
+	const listDigest = "sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"
+	const arm64Digest = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+
+	pinWith := func(rslvr dockref.Resolver, args ...string) (string, ExitCode) {
+		df := dockerfile("FROM --platform=$BUILDPLATFORM golang:1.21 AS build\n")
+		defer os.Remove(df)
+
+		os.Args = append(append([]string{"exe", "pin"}, args...), df)
+		mainOptions := mainOptionsACNew(addPinCommandWith(func(mainOptions *mainOptions) *pinOptions {
+			po := pinOptionsNew(mainOptions)
+			po.resolverFactory = func(_name string) dockref.Resolver {
+				return rslvr
+			}
+			return po
+		}))
+
+		exitCode := doMain(mainOptions)
+
+		dfBytes, e := ioutil.ReadFile(df)
+		assert.Nil(t, e)
+		return string(dfBytes), exitCode
+	}
+
+	t.Run("pins the manifest list with a resolver without platforms", func(t *testing.T) {
+		// like the default dockerd resolver
+		rslvr := dockreftst.MockResolverNew()
+		rslvr.OnResolve(dockref.MustParse("golang:1.21")).
+			Return(dockref.MustParse("golang:1.21@"+listDigest), nil)
+
+		content, exitCode := pinWith(rslvr)
+		assert.Equal(t, ExitSuccess, exitCode)
+		assert.Equal(t, "FROM --platform=$BUILDPLATFORM golang:1.21@"+listDigest+" AS build\n", content)
+	})
+	t.Run("pins the manifest of the platform set with --build-arg", func(t *testing.T) {
+		rslvr := dockreftst.MockPlatformResolverNew()
+		rslvr.OnResolvePlatform(dockref.MustParse("golang:1.21"), dockref.Platform{OS: "linux", Architecture: "arm64"}).
+			Return(dockref.MustParse("golang:1.21@"+arm64Digest), nil)
+
+		content, exitCode := pinWith(rslvr, "--build-arg", "BUILDPLATFORM=linux/arm64")
+		assert.Equal(t, ExitSuccess, exitCode)
+		assert.Equal(t, "FROM --platform=$BUILDPLATFORM golang:1.21@"+arm64Digest+" AS build\n", content)
+	})
+}
+
+func TestPinRewritesArgDefaultUsedAsTag(t *testing.T) {
+	pinWith := func(content string, rslvr dockref.Resolver, args ...string) (string, ExitCode) {
+		df := dockerfile(content)
+		defer os.Remove(df)
+
+		os.Args = append(append([]string{"exe", "pin"}, args...), df)
+		mainOptions := mainOptionsACNew(addPinCommandWith(func(mainOptions *mainOptions) *pinOptions {
+			po := pinOptionsNew(mainOptions)
+			po.resolverFactory = func(_name string) dockref.Resolver {
+				return rslvr
+			}
+			return po
+		}))
+
+		exitCode := doMain(mainOptions)
+
+		dfBytes, e := ioutil.ReadFile(df)
+		assert.Nil(t, e)
+		return string(dfBytes), exitCode
+	}
+
+	rslvr := dockreftst.MockResolverNew()
+	rslvr.OnResolve(dockref.MustParse("alpine:3.18")).
+		Return(dockref.MustParse("alpine:3.18@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+	rslvr.OnResolve(dockref.MustParse("example.com/alpine:3.18")).
+		Return(dockref.MustParse("example.com/alpine:3.18@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+
+	t.Run("rewrites the tag in the ARG", func(t *testing.T) {
+		content, exitCode := pinWith("ARG TAG=3.18\nFROM alpine:${TAG}\n", rslvr)
+		assert.Equal(t, ExitSuccess, exitCode)
+		assert.Equal(t, "ARG TAG=3.18@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\nFROM alpine:${TAG}\n", content)
+	})
+	t.Run("reports changes of the tag with --check", func(t *testing.T) {
+		content, exitCode := pinWith("ARG TAG=3.18\nFROM alpine:${TAG}\n", rslvr, "--check")
+		assert.Equal(t, ExitChangesPending, exitCode)
+		assert.Equal(t, "ARG TAG=3.18\nFROM alpine:${TAG}\n", content)
+	})
+	t.Run("fails when the image cannot be rewritten", func(t *testing.T) {
+		content, exitCode := pinWith("ARG REGISTRY=example.com\nFROM ${REGISTRY}/alpine:3.18\n", rslvr)
+		assert.Equal(t, ExitInvalidFormat, exitCode)
+		assert.Equal(t, "ARG REGISTRY=example.com\nFROM ${REGISTRY}/alpine:3.18\n", content)
+	})
 }
 
 func TestFilenameRequiredWithPin(t *testing.T) {
 	_, _, exitCode, stdout := testMain([]string{"pin"}, addPinCommand)
 	assert.NotEqual(t, 0, exitCode)
 	assert.Contains(t, stdout.String(), "level=error")
-	assert.Contains(t, stdout.String(), "the required argument `InputFile`")
+	assert.Contains(t, stdout.String(), "the required argument `InputFile (at least 1 argument)`")
 }
 
 func TestPinCallsFindExecuteWithPin(t *testing.T) {
@@ -471,6 +637,134 @@ func TestPinWritesToInputFile(t *testing.T) {
 	assert.Equal(t, `FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf`, s)
 }
 
+func TestPinWritesToEachInputFile(t *testing.T) {
+	df1 := dockerfile(`FROM img`)
+	defer os.Remove(df1)
+	df2 := dockerfile(`FROM other:1.0`)
+	defer os.Remove(df2)
+
+	os.Args = []string{"exe", "pin", df1, df2}
+
+	rslvr := dockreftst.MockResolverNew()
+
+	rslvr.OnResolve(dockref.MustParse("img")).Return(
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+	rslvr.OnResolve(dockref.MustParse("other:1.0")).Return(
+		dockref.MustParse("other:1.0@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240"), nil)
+
+	mainOptions := mainOptionsACNew(addPinCommandWith(func(mainOptions *mainOptions) *pinOptions {
+		po := pinOptionsNew(mainOptions)
+
+		po.resolverFactory = func(_name string) dockref.Resolver {
+			return rslvr
+		}
+
+		return po
+	}))
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	dfBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, `FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf`, string(dfBytes))
+
+	dfBytes, e = ioutil.ReadFile(df2)
+	assert.Nil(t, e)
+	assert.Equal(t, `FROM other:1.0@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240`, string(dfBytes))
+}
+
+func pinMainOptionsWithImg(rslvr *dockreftst.MockResolver) *mainOptions {
+	rslvr.OnResolve(dockref.MustParse("img")).Return(
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+	rslvr.OnResolve(dockref.MustParse("img@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")).Return(
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+
+	return mainOptionsACNew(addPinCommandWith(func(mainOptions *mainOptions) *pinOptions {
+		po := pinOptionsNew(mainOptions)
+
+		po.resolverFactory = func(_name string) dockref.Resolver {
+			return rslvr
+		}
+
+		return po
+	}))
+}
+
+func TestPinDryRunPrintsDiffAndDoesNotWrite(t *testing.T) {
+	df1 := dockerfile("FROM img\nRUN true\n")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", "--dry-run", df1}
+
+	mainOptions := pinMainOptionsWithImg(dockreftst.MockResolverNew())
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	dfBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM img\nRUN true\n", string(dfBytes))
+
+	expected := "--- " + df1 + "\n" +
+		"+++ " + df1 + "\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		"-FROM img\n" +
+		"+FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n" +
+		" RUN true\n"
+	assert.Equal(t, expected, mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestPinDryRunPrintsNothingWithoutChanges(t *testing.T) {
+	df1 := dockerfile("FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", "--dry-run", df1}
+
+	rslvr := dockreftst.MockResolverNew()
+	rslvr.OnResolve(dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")).Return(
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+	mainOptions := pinMainOptionsWithImg(rslvr)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Empty(t, mainOptions.stdout.(*bytes.Buffer).String())
+}
+
+func TestPinCheckFailsWhenPinningWouldChange(t *testing.T) {
+	df1 := dockerfile("FROM img\n")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", "--check", df1}
+
+	mainOptions := pinMainOptionsWithImg(dockreftst.MockResolverNew())
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitChangesPending, exitCode)
+	assert.Contains(t, mainOptions.stdout.(*bytes.Buffer).String(), "Pinning would change "+df1)
+
+	dfBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM img\n", string(dfBytes))
+}
+
+func TestPinCheckSucceedsWithoutChanges(t *testing.T) {
+	df1 := dockerfile("FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", "--check", df1}
+
+	rslvr := dockreftst.MockResolverNew()
+	rslvr.OnResolve(dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")).Return(
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+	mainOptions := pinMainOptionsWithImg(rslvr)
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Empty(t, mainOptions.stdout.(*bytes.Buffer).String())
+}
+
 func TestPinWritesToOutputFileAndNotToInputfile(t *testing.T) {
 	df1 := dockerfile(`FROM img`)
 	defer os.Remove(df1)
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+)
+
+type updateOptions struct {
+	MatchingOptions
+
+	FormatOptions FormatOptions `group:"Format Options" description:"Control how the input is evaluated"`
+
+	UpdateOptions struct {
+		Resolver       string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references" choice:"dockerd" choice:"registry" default:"dockerd"`
+		Policy         string `required:"no" long:"policy" description:"Newest version component that may change" choice:"patch" choice:"minor" choice:"major" default:"minor"`
+		Pin            bool   `required:"no" long:"pin" description:"Pin updated image references using the digest"`
+		PlatformDigest string `required:"no" long:"platform-digest" description:"Digest used by --pin for images with a platform, e.g. FROM --platform=linux/arm64: the manifest of the platform or the manifest list" choice:"manifest" choice:"list" default:"manifest"`
+	} `group:"Update Options" description:"Control how the image references are updated"`
+
+	Output struct {
+		OutputFile flags.Filename `required:"no" short:"o" long:"output" description:"Output file to write to. If empty, input file will be used."`
+	} `group:"Output parameters" description:"Output parameters"`
+
+	resolverFactory func(name string) dockref.Resolver
+	matches         bool
+}
+
+func (uo *updateOptions) Execute(args []string) error {
+	return errors.New("use ExecuteWithExitCode instead")
+}
+
+func (uo *updateOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err error) {
+	// TODO code is redundant to other commands
+	mopts := uo.MatchingOptions
+
+	exitCode, err = mopts.Verify()
+	if err != nil {
+		return
+	}
+
+	predicate, err := mopts.getPredicate()
+	if err != nil {
+		return ExitPredicateInvalid, err
+	}
+
+	if uo.Output.OutputFile != "" && !mopts.isSingleInput() {
+		err = errors.New("--output can only be used with a single input file")
+		uo.Log().Errorf("Invalid options: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+
+	exitCode, err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) (bool, error) {
+		uo.matches = false
+		original, errRead := ioutil.ReadAll(inputReader)
+		if errRead != nil {
+			return false, errRead
+		}
+
+		buffer := bytes.NewBuffer(nil)
+
+		errFormat := mopts.WithFormatProcessorDo(inputPath, bytes.NewReader(original), func(processor dockfmt.FormatProcessor) error {
+			processor = processor.WithWriter(buffer)
+			return uo.applyFormatProcessor(predicate, processor)
+		})
+
+		if errFormat != nil {
+			return false, errFormat
+		}
+
+		changed := !bytes.Equal(original, buffer.Bytes())
+		errWrite := uo.WithOutputDo(inputPath, func(outputPath string) error {
+			if !changed && outputPath == inputPath {
+				return nil
+			}
+
+			mode := os.FileMode(0660)
+
+			info, e := os.Stat(outputPath)
+			if e == nil {
+				mode = info.Mode()
+			}
+
+			errWriteFile := ioutil.WriteFile(outputPath, buffer.Bytes(), mode)
+			return errWriteFile
+		})
+		return uo.matches, errWrite
+	})
+
+	if errPredicate := dockproc.PredicateErr(predicate); errPredicate != nil {
+		return ExitUnknownError, errPredicate
+	}
+
+	return exitCode, err
+}
+
+func (uo *updateOptions) applyFormatProcessor(predicate dockproc.Predicate, processor dockfmt.FormatProcessor) error {
+	policy, e := updatePolicy(uo.UpdateOptions.Policy)
+	if e != nil {
+		return e
+	}
+
+	return processor.ProcessLocated(func(original dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		if !dockproc.MatchesLocated(predicate, original, location) {
+			return original, nil
+		}
+		uo.matches = true
+
+		if !dockref.HasVersion(original) {
+			uo.Log().WithField("location", location.String()).Infof("Not updating %s, the tag is not a version", original.Original())
+			return original, nil
+		}
+
+		repo := uo.Resolver()
+		tags, err := repo.FindAllTags(original)
+		if err != nil {
+			uo.Log().WithField("location", location.String()).WithField("error", err.Error()).Errorf("Could not find tags of %s", original.Original())
+			return nil, err
+		}
+
+		updated, err := dockref.NewestTag(original, tags, policy)
+		if err != nil {
+			return nil, err
+		}
+
+		// pinned references stay pinned, a changed tag is pinned again with the digest of the new tag
+		pinned := original.Format()&dockref.FormatHasDigest != 0
+		if updated.Tag() == original.Tag() && (pinned || !uo.UpdateOptions.Pin) {
+			return original, nil
+		}
+
+		format := (original.Format() | dockref.FormatHasTag) &^ dockref.FormatHasDigest
+		if uo.UpdateOptions.Pin || pinned {
+			resolve, err := platformResolve(uo.Log(), repo, location, uo.UpdateOptions.PlatformDigest)
+			if err != nil {
+				return nil, err
+			}
+
+			resolved, err := resolve(updated)
+			if err != nil {
+				uo.Log().WithField("location", location.String()).WithField("error", err.Error()).Errorf("Could not resolve %s", updated.String())
+				return nil, err
+			}
+			updated = resolved
+			format |= dockref.FormatHasDigest
+		}
+
+		return updated.WithRequestedFormat(format)
+	})
+}
+
+func updatePolicy(policyString string) (dockref.UpdatePolicy, error) {
+	switch policyString {
+	case "patch":
+		return dockref.UpdatePolicyPatch, nil
+	case "minor":
+		return dockref.UpdatePolicyMinor, nil
+	case "major":
+		return dockref.UpdatePolicyMajor, nil
+	}
+
+	return -1, errors.Errorf("Invalid update policy '%s'", policyString)
+}
+
+func (uo *updateOptions) Resolver() dockref.Resolver {
+	return uo.resolverFactory(uo.UpdateOptions.Resolver)
+}
+
+func updateOptionsNew(mainOptions *mainOptions) *updateOptions {
+	uo := updateOptions{
+		MatchingOptions: MatchingOptions{
+			mainOpts: mainOptions,
+		},
+		matches: false,
+	}
+
+	uo.UpdateOptions.Policy = "minor"
+	uo.UpdateOptions.PlatformDigest = "manifest"
+	uo.resolverFactory = defaultResolverFactory
+	uo.resolverProvider = uo.Resolver
+	uo.buildArgsProvider = uo.FormatOptions.buildArgs
+	uo.formatNameProvider = uo.FormatOptions.formatName
+
+	return &uo
+}
+
+func addUpdateCommand(
+	mainOptions *mainOptions,
+	adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	return addUpdateCommandWith(updateOptionsNew)(mainOptions, adder)
+}
+
+func addUpdateCommandWith(updateOptionsFactory func(mainOptions *mainOptions) *updateOptions) func(
+	mainOptions *mainOptions,
+	adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	return func(
+		mainOptions *mainOptions,
+		adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+		updateOptions := updateOptionsFactory(mainOptions)
+
+		command, e := adder(mainOptions, "update",
+			"Change image references to newer versions",
+			"Change the tags of image references to the newest version allowed by the policy, keeping the variant (e.g. -alpine)",
+			updateOptions)
+		return command, e
+	}
+}
+
+func (uo *updateOptions) WithOutputDo(inputPath string, action func(outputPath string) error) error {
+	filename := string(uo.Output.OutputFile)
+	if filename != "" {
+		return action(filename)
+	}
+
+	return uo.MatchingOptions.WithOutputDo(inputPath, action)
+}
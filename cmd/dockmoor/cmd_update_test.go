@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/stretchr/testify/assert"
+)
+
+type updateOptionsTest struct {
+	*updateOptions
+
+	mainOptionsTest *mainOptionsTest
+	mockResolver    *dockreftst.MockResolver
+}
+
+func updateOptionsTestNew() *updateOptionsTest {
+	mainOptions := mainOptionsTestNew()
+
+	resolver := dockreftst.MockResolverNew()
+
+	updateOptions := &updateOptionsTest{
+		updateOptions:   updateOptionsNew(mainOptions.mainOptions),
+		mainOptionsTest: mainOptions,
+		mockResolver:    resolver,
+	}
+	updateOptions.resolverFactory = func(_name string) dockref.Resolver {
+		return resolver
+	}
+
+	return updateOptions
+}
+
+func nginxTags() []dockref.Reference {
+	return []dockref.Reference{
+		dockref.MustParse("nginx:latest"),
+		dockref.MustParse("nginx:1.14.1"),
+		dockref.MustParse("nginx:1.14.2"),
+		dockref.MustParse("nginx:1.15.6"),
+		dockref.MustParse("nginx:1.15"),
+		dockref.MustParse("nginx:1.15.6-alpine"),
+		dockref.MustParse("nginx:1.15.8-alpine"),
+		dockref.MustParse("nginx:2.0.0"),
+	}
+}
+
+func TestUpdateCommandUpdates(t *testing.T) {
+	uo := updateOptionsTestNew()
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.14.1")).Return(nginxTags(), nil)
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.14.1-alpine")).Return(nginxTags(), nil)
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.15.6-alpine")).Return(nginxTags(), nil)
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.14.1@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")).Return(nginxTags(), nil)
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.15.8-alpine@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")).Return(nginxTags(), nil)
+	uo.mockResolver.OnResolve(dockref.MustParse("nginx:1.14.2")).
+		Return(dockref.MustParse("nginx:1.14.2@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+
+	processorMock := &FormatProcessorMock{}
+
+	update := func(t *testing.T, refStr, expected string) {
+		ran := false
+		processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+			ref, e := imageNameProcessor(dockref.MustParse(refStr))
+			assert.Nil(t, e)
+			assert.Equal(t, expected, ref.String())
+			ran = true
+			return nil
+		}
+		predicate, e := dockproc.AnyPredicateNew()
+		assert.Nil(t, e)
+
+		err := uo.applyFormatProcessor(predicate, processorMock)
+		assert.Nil(t, err)
+		assert.True(t, ran)
+	}
+
+	t.Run("patch", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "patch"
+		update(t, "nginx:1.14.1", "nginx:1.14.2")
+	})
+	t.Run("minor", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "minor"
+		update(t, "nginx:1.14.1", "nginx:1.15.6")
+	})
+	t.Run("major", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "major"
+		update(t, "nginx:1.14.1", "nginx:2.0.0")
+	})
+	t.Run("keeps variant", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "major"
+		update(t, "nginx:1.15.6-alpine", "nginx:1.15.8-alpine")
+	})
+	t.Run("unchanged without newer version", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "patch"
+		update(t, "nginx:1.14.1-alpine", "nginx:1.14.1-alpine")
+	})
+	t.Run("pins updated references that were pinned", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "patch"
+		update(t, "nginx:1.14.1@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991", "nginx:1.14.2@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")
+	})
+	t.Run("keeps pinned references without newer version", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "patch"
+		update(t, "nginx:1.15.8-alpine@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991", "nginx:1.15.8-alpine@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")
+	})
+	t.Run("ignores tags without version", func(t *testing.T) {
+		uo.UpdateOptions.Policy = "major"
+		update(t, "nginx", "nginx")
+		update(t, "nginx:latest", "nginx:latest")
+	})
+}
+
+func TestUpdateCommandPins(t *testing.T) {
+	uo := updateOptionsTestNew()
+	uo.UpdateOptions.Pin = true
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.14.1")).Return(nginxTags(), nil)
+	uo.mockResolver.OnResolve(dockref.MustParse("nginx:1.15.6")).
+		Return(dockref.MustParse("nginx:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"), nil)
+
+	processorMock := &FormatProcessorMock{}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		ref, e := imageNameProcessor(dockref.MustParse("nginx:1.14.1"))
+		assert.Nil(t, e)
+		assert.Equal(t, "nginx:1.15.6@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991", ref.String())
+		return nil
+	}
+	predicate, e := dockproc.AnyPredicateNew()
+	assert.Nil(t, e)
+
+	err := uo.applyFormatProcessor(predicate, processorMock)
+	assert.Nil(t, err)
+}
+
+func TestUpdateCommandReportsResolverErrors(t *testing.T) {
+	uo := updateOptionsTestNew()
+	expected := errors.New("expected")
+	uo.mockResolver.OnFindAllTags(dockref.MustParse("nginx:1.14.1")).Return([]dockref.Reference(nil), expected)
+
+	processorMock := &FormatProcessorMock{}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		_, e := imageNameProcessor(dockref.MustParse("nginx:1.14.1"))
+		return e
+	}
+	predicate, e := dockproc.AnyPredicateNew()
+	assert.Nil(t, e)
+
+	err := uo.applyFormatProcessor(predicate, processorMock)
+	assert.Equal(t, expected, err)
+}
+
+func TestUpdateInvalidPolicy(t *testing.T) {
+	_, _, exitCode, stdout := testMain([]string{"update", "--policy", "invalid", "fileName"}, addUpdateCommand)
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, stdout.String(), "level=error")
+}
+
+func TestUpdateWritesToInputFile(t *testing.T) {
+	df1 := dockerfile(`FROM img:1.2.3`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", "--pin", df1}
+
+	rslvr := dockreftst.MockResolverNew()
+
+	rslvr.OnFindAllTags(dockref.MustParse("img:1.2.3")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3"),
+		dockref.MustParse("img:1.3.0"),
+	}, nil)
+	rslvr.OnResolve(dockref.MustParse("img:1.3.0")).Return(
+		dockref.MustParse("img:1.3.0@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"), nil)
+
+	mainOptions := mainOptionsACNew(addUpdateCommandWith(func(mainOptions *mainOptions) *updateOptions {
+		uo := updateOptionsNew(mainOptions)
+
+		uo.resolverFactory = func(_name string) dockref.Resolver {
+			return rslvr
+		}
+
+		return uo
+	}))
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	dfBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+
+	assert.Equal(t, `FROM img:1.3.0@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf`, string(dfBytes))
+}
+
+func TestUpdateDoesNotWriteUnchangedFiles(t *testing.T) {
+	df1 := dockerfile(`FROM img:1.3.0@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf`)
+	defer os.Remove(df1)
+
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.Nil(t, os.Chtimes(df1, modified, modified))
+
+	os.Args = []string{"exe", "update", df1}
+
+	rslvr := dockreftst.MockResolverNew()
+	rslvr.OnFindAllTags(dockref.MustParse("img:1.3.0@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3"),
+		dockref.MustParse("img:1.3.0"),
+	}, nil)
+
+	mainOptions := mainOptionsACNew(addUpdateCommandWith(func(mainOptions *mainOptions) *updateOptions {
+		uo := updateOptionsNew(mainOptions)
+		uo.resolverFactory = func(_name string) dockref.Resolver {
+			return rslvr
+		}
+		return uo
+	}))
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	info, e := os.Stat(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, modified, info.ModTime())
+}
+
+func TestUpdateLogsLocationOfErrors(t *testing.T) {
+	df1 := dockerfile("FROM scratch\nFROM img:1.2.3\n")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", df1}
+
+	rslvr := dockreftst.MockResolverNew()
+	rslvr.OnFindAllTags(dockref.MustParse("img:1.2.3")).Return([]dockref.Reference(nil), errors.New("expected"))
+
+	mainOptions := mainOptionsACNew(addUpdateCommandWith(func(mainOptions *mainOptions) *updateOptions {
+		uo := updateOptionsNew(mainOptions)
+
+		uo.resolverFactory = func(_name string) dockref.Resolver {
+			return rslvr
+		}
+
+		return uo
+	}))
+
+	exitCode := doMain(mainOptions)
+
+	assert.NotEqual(t, ExitSuccess, exitCode)
+	assert.Contains(t, mainOptions.stdout.(*bytes.Buffer).String(), `location="`+df1+`:2:6"`)
+}
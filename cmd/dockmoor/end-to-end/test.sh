@@ -558,6 +558,152 @@ cp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea
 
 
 
+CASE_ID=25
+CASE_NAME=pinMostPreciseVersionWithDockerd
+( # pin all image references to the most precise version of the same image
+rm -f pin-examples/Dockerfile-testimagea
+cp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea
+
+#tag::pinMostPreciseVersionWithDockerd[]
+dockmoor pin --tag-mode=most-precise-version pin-examples/Dockerfile-testimagea
+#end::pinMostPreciseVersionWithDockerd[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 0 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stdout="$(cat $RESULTS/${CASE_NAME}.stdout)"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+[[ -z $stdout ]] || fail ${CASE_ID} "Expected empty stdout"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+cmp pin-examples/Dockerfile-testimagea-any-most-precise-version.expected pin-examples/Dockerfile-testimagea || fail ${CASE_ID} "unexpected result"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+# cleanup
+rm -f pin-examples/Dockerfile-testimagea
+cp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea
+
+
+
+## update command
+
+CASE_ID=26
+CASE_NAME=updateMinorWithDockerd
+( # update all image references to the newest minor version
+rm -f pin-examples/Dockerfile-testimagea
+cp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea
+
+#tag::updateMinorWithDockerd[]
+dockmoor update --policy=minor pin-examples/Dockerfile-testimagea
+#end::updateMinorWithDockerd[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 0 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stdout="$(cat $RESULTS/${CASE_NAME}.stdout)"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+[[ -z $stdout ]] || fail ${CASE_ID} "Expected empty stdout"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+cmp pin-examples/Dockerfile-testimagea-update-minor.expected pin-examples/Dockerfile-testimagea || fail ${CASE_ID} "unexpected result"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+# cleanup
+rm -f pin-examples/Dockerfile-testimagea
+cp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea
+
+
+
+CASE_ID=27
+CASE_NAME=listOutdatedWithDockerd
+( # list all image references with newer versions known to the docker daemon
+#tag::listOutdatedWithDockerd[]
+dockmoor list --outdated pin-examples/Dockerfile-testimagea.org
+#end::listOutdatedWithDockerd[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 0 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stdout="$(cat $RESULTS/${CASE_NAME}.stdout)"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+hasLine "$stdout" "menedev/testimagea:1" || fail ${CASE_ID} "Unexpected stdout"
+hasLine "$stdout" "menedev/testimagea:1.0" || fail ${CASE_ID} "Unexpected stdout"
+hasLine "$stdout" "menedev/testimagea:1.1.1" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "menedev/testimagea:2" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "menedev/testimagea:2.0.0" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "menedev/testimagea:latest" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "menedev/testimagea" || fail ${CASE_ID} "Unexpected stdout"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+
+
+
+CASE_ID=28
+CASE_NAME=listLatestInFolderRecursive
+( # list all image references with latest/no tag in all files of a folder
+#tag::listLatestInFolderRecursive[]
+dockmoor list --latest some-folder/
+#end::listLatestInFolderRecursive[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 0 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stdout="$(cat $RESULTS/${CASE_NAME}.stdout)"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+hasLine "$stdout" "nginx" || fail ${CASE_ID} "Unexpected stdout"
+hasLine "$stdout" "nginx:latest" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "nginx:1.15.3" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "nginx@sha256:db5acc22920799fe387a903437eb89387607e5b3f63cf0f4472ac182d7bad644" || fail ${CASE_ID} "Unexpected stdout"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+
+
+
+CASE_ID=29
+CASE_NAME=pinDryRunWithDockerd
+( # show the changes pinning would make without changing the file
+#tag::pinDryRunWithDockerd[]
+dockmoor pin --dry-run pin-examples/Dockerfile-testimagea
+#end::pinDryRunWithDockerd[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 0 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stdout="$(cat $RESULTS/${CASE_NAME}.stdout)"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+hasLine "$stdout" "--- pin-examples/Dockerfile-testimagea" || fail ${CASE_ID} "Unexpected stdout"
+hasLine "$stdout" "+++ pin-examples/Dockerfile-testimagea" || fail ${CASE_ID} "Unexpected stdout"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+cmp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea || fail ${CASE_ID} "file was changed"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+
+
+CASE_ID=30
+CASE_NAME=pinCheckWithDockerd
+( # fail when pinning would change the file
+#tag::pinCheckWithDockerd[]
+dockmoor pin --check pin-examples/Dockerfile-testimagea
+#end::pinCheckWithDockerd[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 7 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+cmp pin-examples/Dockerfile-testimagea.org pin-examples/Dockerfile-testimagea || fail ${CASE_ID} "file was changed"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+
+
+
+CASE_ID=31
+CASE_NAME=listTemplateInFile
+( # list image references in a custom format
+#tag::listTemplateInFile[]
+dockmoor list --output-format=template --template='{{.File}}:{{.Line}}: {{.Path}} {{.Tag}}' --domain=example.com Dockerfile
+#end::listTemplateInFile[]
+) >$RESULTS/${CASE_NAME}.stdout 2>$RESULTS/${CASE_NAME}.stderr
+exitCode=$?
+[ $exitCode -eq 0 ] || fail ${CASE_ID} "Unexpected exit code $exitCode"
+stdout="$(cat $RESULTS/${CASE_NAME}.stdout)"
+stderr="$(cat $RESULTS/${CASE_NAME}.stderr)"
+hasLine "$stdout" "Dockerfile:13: image-name 1.12" || fail ${CASE_ID} "Unexpected stdout"
+hasLine "$stdout" "Dockerfile:20: other-image latest" || fail ${CASE_ID} "Unexpected stdout"
+hasNoLine "$stdout" "Dockerfile:3: library/image-name 1.12" || fail ${CASE_ID} "Unexpected stdout"
+[[ -z $stderr ]] || fail ${CASE_ID} "Expected empty stderr"
+echo $exitCode >$RESULTS/${CASE_NAME}.exitCode
+
+
+
 # When we reach this, everything is fine!
 echo "All tests passed!"
 
@@ -10,4 +10,5 @@ const (
 	ExitInvalidFormat
 	ExitCouldNotOpenFile
 	ExitPredicateInvalid
+	ExitChangesPending
 )
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+)
+
+// listEntry is a matched image reference as written by the list command
+type listEntry struct {
+	File        string   `json:"file"`
+	Format      string   `json:"format"`
+	Line        int      `json:"line"`
+	Column      int      `json:"column"`
+	Stage       string   `json:"stage"`
+	Instruction string   `json:"instruction"`
+	Original    string   `json:"original"`
+	Domain      string   `json:"domain"`
+	Path        string   `json:"path"`
+	Tag         string   `json:"tag"`
+	Digest      string   `json:"digest"`
+	Flags       []string `json:"flags"`
+}
+
+func listEntryNew(formatName string, r dockref.Reference, location dockfmt.Location) listEntry {
+	return listEntry{
+		File:        location.File,
+		Format:      formatName,
+		Line:        location.Line,
+		Column:      location.Column,
+		Stage:       location.Stage,
+		Instruction: location.Instruction,
+		Original:    r.Original(),
+		Domain:      r.Domain(),
+		Path:        r.Path(),
+		Tag:         r.Tag(),
+		Digest:      r.DigestString(),
+		Flags:       r.Format().Names(),
+	}
+}
+
+type listWriter interface {
+	Write(entry listEntry) error
+	Close() error
+}
+
+func (lo *listOptions) listWriterNew() (listWriter, error) {
+	output := lo.Output.OutputFormat
+	templateText := lo.Output.Template
+
+	if templateText != "" && output != "template" {
+		return nil, errors.New("--template can only be used with --output-format=template")
+	}
+
+	writer := lo.Stdout()
+	switch output {
+	case "", "text":
+		return &textListWriter{writer: writer}, nil
+	case "json":
+		return &jsonListWriter{writer: writer, entries: make([]listEntry, 0)}, nil
+	case "csv":
+		return csvListWriterNew(writer)
+	case "template":
+		if templateText == "" {
+			return nil, errors.New("--output-format=template requires --template")
+		}
+		tmpl, err := template.New("list").Parse(templateText)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid template")
+		}
+		return &templateListWriter{writer: writer, template: tmpl}, nil
+	}
+
+	return nil, errors.Errorf("Unknown output format '%s'", output)
+}
+
+type textListWriter struct {
+	writer io.Writer
+}
+
+func (w *textListWriter) Write(entry listEntry) error {
+	_, err := fmt.Fprintf(w.writer, "%s\n", entry.Original)
+	return err
+}
+
+func (w *textListWriter) Close() error {
+	return nil
+}
+
+// jsonListWriter collects all entries and writes them as one array
+type jsonListWriter struct {
+	writer  io.Writer
+	entries []listEntry
+}
+
+func (w *jsonListWriter) Write(entry listEntry) error {
+	w.entries = append(w.entries, entry)
+	return nil
+}
+
+func (w *jsonListWriter) Close() error {
+	encoder := json.NewEncoder(w.writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(w.entries)
+}
+
+type csvListWriter struct {
+	writer *csv.Writer
+}
+
+func csvListWriterNew(writer io.Writer) (listWriter, error) {
+	w := &csvListWriter{writer: csv.NewWriter(writer)}
+	err := w.writer.Write([]string{"file", "format", "line", "column", "stage", "instruction", "original", "domain", "path", "tag", "digest", "flags"})
+	return w, err
+}
+
+func (w *csvListWriter) Write(entry listEntry) error {
+	return w.writer.Write([]string{
+		entry.File,
+		entry.Format,
+		strconv.Itoa(entry.Line),
+		strconv.Itoa(entry.Column),
+		entry.Stage,
+		entry.Instruction,
+		entry.Original,
+		entry.Domain,
+		entry.Path,
+		entry.Tag,
+		entry.Digest,
+		strings.Join(entry.Flags, "|"),
+	})
+}
+
+func (w *csvListWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type templateListWriter struct {
+	writer   io.Writer
+	template *template.Template
+}
+
+func (w *templateListWriter) Write(entry listEntry) error {
+	err := w.template.Execute(w.writer, entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w.writer)
+	return err
+}
+
+func (w *templateListWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+)
+
+func listOutput(t *testing.T, args ...string) (ExitCode, string) {
+	df1 := dockerfile("FROM nginx:1.15@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991\nFROM example.com/img\n")
+	defer os.Remove(df1)
+
+	os.Args = append(append([]string{"exe", "list"}, args...), df1)
+	mainOptions := mainOptionsACNew(addListCommand)
+	exitCode := doMain(mainOptions)
+
+	output := mainOptions.stdout.(*bytes.Buffer).String()
+	return exitCode, output
+}
+
+func TestListEntryNew(t *testing.T) {
+	location := dockfmt.Location{File: "Dockerfile", Line: 3, Column: 6, Stage: "build", Instruction: "FROM"}
+	entry := listEntryNew("Dockerfile", dockref.MustParse("example.com/org/img:1.2@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"), location)
+
+	assert.Equal(t, listEntry{
+		File:        "Dockerfile",
+		Format:      "Dockerfile",
+		Line:        3,
+		Column:      6,
+		Stage:       "build",
+		Instruction: "FROM",
+		Original:    "example.com/org/img:1.2@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991",
+		Domain:      "example.com",
+		Path:        "org/img",
+		Tag:         "1.2",
+		Digest:      "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991",
+		Flags:       []string{"name", "tag", "digest"},
+	}, entry)
+}
+
+func TestListOutputText(t *testing.T) {
+	exitCode, output := listOutput(t, "--output-format=text")
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "nginx:1.15@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991\nexample.com/img\n", output)
+}
+
+func TestListOutputJson(t *testing.T) {
+	exitCode, output := listOutput(t, "--output-format=json")
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	var entries []listEntry
+	err := json.Unmarshal([]byte(output), &entries)
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "Dockerfile", entries[0].Format)
+		assert.Equal(t, 1, entries[0].Line)
+		assert.Equal(t, 6, entries[0].Column)
+		assert.Equal(t, "FROM", entries[0].Instruction)
+		assert.Equal(t, "nginx:1.15@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991", entries[0].Original)
+		assert.Equal(t, "docker.io", entries[0].Domain)
+		assert.Equal(t, "library/nginx", entries[0].Path)
+		assert.Equal(t, "1.15", entries[0].Tag)
+		assert.Equal(t, "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991", entries[0].Digest)
+		assert.Equal(t, []string{"name", "tag", "digest"}, entries[0].Flags)
+
+		assert.Equal(t, 2, entries[1].Line)
+		assert.Equal(t, "example.com", entries[1].Domain)
+		assert.Equal(t, "", entries[1].Tag)
+		assert.Equal(t, []string{"name"}, entries[1].Flags)
+	}
+}
+
+func TestListOutputJsonWithoutMatchesIsEmptyArray(t *testing.T) {
+	exitCode, output := listOutput(t, "--output-format=json", "--untagged", "--domain=other.com")
+
+	assert.Equal(t, ExitNotFound, exitCode)
+	assert.Equal(t, "[]\n", output)
+}
+
+func TestListOutputCsv(t *testing.T) {
+	exitCode, output := listOutput(t, "--output-format=csv", "--domain=example.com")
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Contains(t, output, "file,format,line,column,stage,instruction,original,domain,path,tag,digest,flags\n")
+	assert.Contains(t, output, ",Dockerfile,2,6,,FROM,example.com/img,example.com,img,,,name\n")
+}
+
+func TestListOutputTemplate(t *testing.T) {
+	exitCode, output := listOutput(t, "--output-format=template", "--template={{.Path}} {{.Tag}}")
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.Equal(t, "library/nginx 1.15\nimg \n", output)
+}
+
+func TestListOutputTemplateErrors(t *testing.T) {
+	cases := map[string][]string{
+		"missing template":         {"--output-format=template"},
+		"invalid template":         {"--output-format=template", "--template={{.Path"},
+		"template without output":  {"--template={{.Path}}"},
+		"--output is not a format": {"--output=json"},
+	}
+
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			exitCode, output := listOutput(t, args...)
+
+			assert.Equal(t, ExitInvalidParams, exitCode)
+			assert.Contains(t, output, "level=error")
+		})
+	}
+}
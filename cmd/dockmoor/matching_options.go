@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
@@ -26,17 +29,22 @@ const (
 
 	digestsPred  = "digest"
 	unpinnedPred = "unpinned"
+
+	stagePred = "stage"
 )
 
 var namePredicateNames = []string{domainPred, namePred, pathPred, familiarNamePred}
 var tagPredicateNames = []string{latestPred, outdatedPred, untaggedPred, tagPred}
 var digestPredicateNames = []string{digestsPred, unpinnedPred}
+var stagePredicateNames = []string{stagePred}
 
 var predicateNames = append(
 	append(
-		namePredicateNames,
-		tagPredicateNames...),
-	digestPredicateNames...)
+		append(
+			namePredicateNames,
+			tagPredicateNames...),
+		digestPredicateNames...),
+	stagePredicateNames...)
 
 func indexOf(item string, slice []string) int {
 	for i, v := range slice {
@@ -109,7 +117,7 @@ type MatchingOptions struct {
 	TagPredicates struct {
 		Untagged bool     `required:"no" long:"untagged" description:"Matches images with no tag"`
 		Latest   bool     `required:"no" long:"latest" description:"Matches images with latest or no tag. References with digest are only matched when explicit latest tag is present."`
-		Outdated bool     `required:"no" long:"outdated" description:"Matches all images with newer versions available" hidden:"true"`
+		Outdated bool     `required:"no" long:"outdated" description:"Matches all images with newer versions available, queries the resolver for all tags"`
 		Tags     []string `required:"no" long:"tag" description:"Matches all images matching one of the specified tag. Surround with '/' for regex i.e. /regex/."`
 	} `group:"Tag Predicates" description:"Limit matched image references depending on their tag"`
 
@@ -118,11 +126,43 @@ type MatchingOptions struct {
 		Digests  []string `required:"no" long:"digest" description:"Matches all image references with one of the provided digests."`
 	} `group:"Digest Predicates" description:"Limit matched image references depending on their digest"`
 
+	StagePredicates struct {
+		Stages []string `required:"no" long:"stage" description:"Matches all image references used in one of the specified build stages of Dockerfiles (e.g. \"builder\"). Surround with '/' for regex i.e. /regex/."`
+	} `group:"Stage Predicates" description:"Limit matched image references depending on the build stage they are used in"`
+
 	Positional struct {
-		InputFile flags.Filename `required:"yes"`
+		InputFiles []flags.Filename `required:"1" positional-arg-name:"InputFile" description:"Files, directories (searched recursively) or glob patterns to process, - for stdin"`
 	} `positional-args:"yes"`
 
-	mainOpts *mainOptions
+	mainOpts           *mainOptions
+	resolverProvider   func() dockref.Resolver
+	buildArgsProvider  func() map[string]string
+	formatNameProvider func() string
+}
+
+// FormatOptions control how the formats evaluate the input, they are added to every command
+type FormatOptions struct {
+	Format    string   `required:"no" long:"format" value-name:"NAME" description:"Processes the input files as format NAME (e.g. Dockerfile) instead of identifying the format"`
+	BuildArgs []string `required:"no" long:"build-arg" value-name:"NAME=value" description:"Sets a build argument like docker build --build-arg, replaces the default of a global ARG in Dockerfiles. Without value the environment variable is used."`
+}
+
+// formatName returns the value of --format, empty when the format is identified
+func (fopts *FormatOptions) formatName() string {
+	return fopts.Format
+}
+
+// buildArgs returns the values of --build-arg by name, names without value take the value from the environment
+func (fopts *FormatOptions) buildArgs() map[string]string {
+	buildArgs := make(map[string]string)
+	for _, buildArg := range fopts.BuildArgs {
+		parts := strings.SplitN(buildArg, "=", 2)
+		if len(parts) == 2 {
+			buildArgs[parts[0]] = parts[1]
+		} else if value, ok := os.LookupEnv(parts[0]); ok {
+			buildArgs[parts[0]] = value
+		}
+	}
+	return buildArgs
 }
 
 func (mopts *MatchingOptions) mainOptions() *mainOptions {
@@ -159,6 +199,8 @@ func (mopts *MatchingOptions) isSetPredicateByName(name string) bool {
 		return mopts.DigestPredicates.Digests != nil
 	case unpinnedPred:
 		return mopts.DigestPredicates.Unpinned
+	case stagePred:
+		return mopts.StagePredicates.Stages != nil
 	}
 
 	panic(fmt.Sprintf("Unknown predicate name %s", name))
@@ -205,9 +247,32 @@ func (mopts *MatchingOptions) Verify() (ExitCode, error) {
 		return ExitInvalidParams, result.ErrorOrNil()
 	}
 
+	errFormat := mopts.verifyFormatName()
+	if errFormat != nil {
+		mopts.Log().Errorf("Invalid options: %s\n", errFormat.Error())
+		return ExitInvalidParams, errFormat
+	}
+
 	return ExitSuccess, result.ErrorOrNil()
 }
 
+// verifyFormatName checks that the format set with --format is known
+func (mopts *MatchingOptions) verifyFormatName() error {
+	name := mopts.formatName()
+	if name == "" {
+		return nil
+	}
+	_, err := dockfmt.FormatByName(mopts.mainOptions().FormatProvider(), name)
+	return err
+}
+
+func (mopts *MatchingOptions) formatName() string {
+	if mopts.formatNameProvider == nil {
+		return ""
+	}
+	return mopts.formatNameProvider()
+}
+
 var latestPredicateFactory = dockproc.LatestPredicateNew
 var latestUnpinnedFactory = dockproc.UnpinnedPredicateNew
 var anyPredicateFactory = dockproc.AnyPredicateNew
@@ -218,7 +283,9 @@ var pathsPredicateFactory = dockproc.PathsPredicateNew
 var untaggedPredicateFactory = dockproc.UntaggedPredicateNew
 var tagsPredicateFactory = dockproc.TagsPredicateNew
 var digestsPredicateFactory = dockproc.DigestsPredicateNew
+var stagesPredicateFactory = dockproc.StagesPredicateNew
 var andPredicateFactory = dockproc.AndPredicateNew
+var outdatedPredicateFactory = dockproc.OutdatedPredicateNew
 
 func (mopts *MatchingOptions) getPredicate() (dockproc.Predicate, error) {
 	anyPredicate, e := anyPredicateFactory()
@@ -271,6 +338,12 @@ func (mopts *MatchingOptions) getPredicate() (dockproc.Predicate, error) {
 		predicates = append(predicates, p)
 	}
 
+	if mopts.TagPredicates.Outdated {
+		p, e := mopts.outdatedPredicate()
+		err = multierror.Append(err, e)
+		predicates = append(predicates, p)
+	}
+
 	if mopts.DigestPredicates.Unpinned {
 		p, e := latestUnpinnedFactory()
 		err = multierror.Append(err, e)
@@ -283,6 +356,12 @@ func (mopts *MatchingOptions) getPredicate() (dockproc.Predicate, error) {
 		predicates = append(predicates, p)
 	}
 
+	if mopts.StagePredicates.Stages != nil {
+		p, e := stagesPredicateFactory(mopts.StagePredicates.Stages)
+		err = multierror.Append(err, e)
+		predicates = append(predicates, p)
+	}
+
 	switch len(predicates) {
 	case 0:
 		return anyPredicate, err.ErrorOrNil()
@@ -295,6 +374,14 @@ func (mopts *MatchingOptions) getPredicate() (dockproc.Predicate, error) {
 	}
 }
 
+func (mopts *MatchingOptions) outdatedPredicate() (dockproc.Predicate, error) {
+	if mopts.resolverProvider == nil {
+		return nil, errors.New("--outdated is not supported by this command")
+	}
+
+	return outdatedPredicateFactory(mopts.resolverProvider(), mopts.Log())
+}
+
 func (mopts *MatchingOptions) open(readable string) (io.ReadCloser, error) {
 	return mopts.mainOpts.readableOpener(readable)
 }
@@ -308,41 +395,235 @@ func saveClose(log *logrus.Logger, readCloser io.Closer) {
 	}
 }
 
-func (mopts *MatchingOptions) WithInputDo(action func(filePathInput string, fpInput io.Reader) error) error {
+// inputFile is a file to process. Files that were not named explicitly, but found in a directory or by a glob
+// pattern, are skipped when their format is unknown.
+type inputFile struct {
+	path  string
+	found bool
+}
+
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isSingleInput returns true when exactly one file is named explicitly
+func (mopts *MatchingOptions) isSingleInput() bool {
+	if len(mopts.Positional.InputFiles) != 1 {
+		return false
+	}
+
+	path := string(mopts.Positional.InputFiles[0])
+	return path == "-" || !isGlobPattern(path) && !isDirectory(path)
+}
+
+// searchedHiddenDirectories are hidden directories that contain files of supported formats
+var searchedHiddenDirectories = map[string]struct{}{
+	".circleci": {},
+	".github":   {},
+}
+
+func walkFiles(root string) ([]inputFile, error) {
+	files := make([]inputFile, 0)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if _, ok := searchedHiddenDirectories[info.Name()]; ok {
+				return nil
+			}
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, inputFile{path: path, found: true})
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+func (mopts *MatchingOptions) inputFiles() ([]inputFile, error) {
+	files := make([]inputFile, 0)
+	seen := make(map[string]bool)
+	add := func(found ...inputFile) {
+		for _, f := range found {
+			if !seen[f.path] {
+				seen[f.path] = true
+				files = append(files, f)
+			}
+		}
+	}
+
+	for _, input := range mopts.Positional.InputFiles {
+		path := string(input)
+
+		switch {
+		case path == "-":
+			add(inputFile{path: path})
+		case isGlobPattern(path):
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid glob pattern '%s'", path)
+			}
+			if len(matches) == 0 {
+				return nil, &os.PathError{Op: "glob", Path: path, Err: os.ErrNotExist}
+			}
+			for _, match := range matches {
+				found, err := walkFiles(match)
+				if err != nil {
+					return nil, err
+				}
+				add(found...)
+			}
+		case isDirectory(path):
+			found, err := walkFiles(path)
+			if err != nil {
+				return nil, err
+			}
+			add(found...)
+		default:
+			// errors are reported when the file is opened
+			add(inputFile{path: path})
+		}
+	}
+
+	return files, nil
+}
+
+// WithInputDo calls action for each input file, action returns whether the file contains matching image references.
+// All files are processed, even when some of them fail. The results are combined: the exit code is ExitSuccess when
+// any file matched without error, otherwise the exit code of the most severe error or ExitNotFound.
+// Files found in directories or by glob patterns are skipped when their format is unknown or ambiguous.
+func (mopts *MatchingOptions) WithInputDo(action func(filePathInput string, fpInput io.Reader) (bool, error)) (ExitCode, error) {
+	log := mopts.Log()
+
+	files, err := mopts.inputFiles()
+	if err != nil {
+		exitCode, _ := exitCodeFromError(err)
+		return exitCode, err
+	}
+
+	matches := false
+	exitCode := ExitNotFound
+	var errs []error
+	for _, file := range files {
+		fileMatches, err := mopts.withFileDo(file.path, action)
+
+		if file.found {
+			switch err.(type) {
+			case dockfmt.UnknownFormatError:
+				log.Debugf("Skipping %s, the format is unknown", file.path)
+				continue
+			case dockfmt.AmbiguousFormatError:
+				log.Warnf("Skipping %s, the format is ambiguous, use --format to process it", file.path)
+				continue
+			}
+		}
+
+		if err != nil {
+			if len(files) > 1 {
+				log.WithField("error", err.Error()).Errorf("Could not process %s", file.path)
+			}
+			errs = append(errs, err)
+			if errExitCode, _ := exitCodeFromError(err); moreSevere(errExitCode, exitCode) {
+				exitCode = errExitCode
+			}
+			continue
+		}
+
+		matches = matches || fileMatches
+	}
+
+	if matches {
+		exitCode = ExitSuccess
+	}
+
+	switch len(errs) {
+	case 0:
+		return exitCode, nil
+	case 1:
+		return exitCode, errs[0]
+	}
+	return exitCode, multierror.Append(nil, errs...)
+}
+
+// exitCodeSeverity orders the exit codes of failed files, the least severe first
+var exitCodeSeverity = []ExitCode{
+	ExitNotFound,
+	ExitInvalidFormat,
+	ExitCouldNotOpenFile,
+	ExitUnknownError,
+}
+
+func moreSevere(exitCode ExitCode, than ExitCode) bool {
+	return indexOfExitCode(exitCode) > indexOfExitCode(than)
+}
+
+func indexOfExitCode(exitCode ExitCode) int {
+	for i, e := range exitCodeSeverity {
+		if e == exitCode {
+			return i
+		}
+	}
+	return -1
+}
+
+func (mopts *MatchingOptions) withFileDo(filePathInput string, action func(filePathInput string, fpInput io.Reader) (bool, error)) (bool, error) {
 	log := mopts.Log()
 
-	filePathInput := string(mopts.Positional.InputFile)
 	fpInput, err := mopts.open(filePathInput)
 	defer saveClose(log, fpInput)
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	err = action(filePathInput, fpInput)
-
-	return err
+	return action(filePathInput, fpInput)
 }
 
-func (mopts *MatchingOptions) WithFormatProcessorDo(fpInput io.Reader, action func(processor dockfmt.FormatProcessor) error) error {
+func (mopts *MatchingOptions) WithFormatProcessorDo(filename string, fpInput io.Reader, action func(processor dockfmt.FormatProcessor) error) error {
 	log := mopts.Log()
 
 	formatProvider := mopts.mainOptions().FormatProvider()
-	filename := string(mopts.Positional.InputFile)
-	fileFormat, formatError := dockfmt.IdentifyFormat(log, formatProvider, fpInput, filename)
+	var fileFormat dockfmt.Format
+	var document dockfmt.Document
+	var formatError error
+	if name := mopts.formatName(); name != "" {
+		fileFormat, document, formatError = dockfmt.ValidateNamedFormat(log, formatProvider, name, fpInput, filename)
+	} else {
+		fileFormat, document, formatError = dockfmt.IdentifyFormat(log, formatProvider, fpInput, filename)
+	}
 
-	if formatError != nil {
+	if fileFormat == nil {
 		return formatError
 	}
 
-	formatProcessor := dockfmt.FormatProcessorNew(fileFormat, log, fpInput)
+	if formatError != nil {
+		// the other formats did not match, which is expected
+		log.WithField("error", formatError.Error()).Debugf("Identified format %s", fileFormat.Name())
+	}
+
+	formatProcessor := dockfmt.FormatProcessorNew(fileFormat, log, document).WithFilename(filename)
+	if mopts.buildArgsProvider != nil {
+		if buildArgs := mopts.buildArgsProvider(); len(buildArgs) > 0 {
+			formatProcessor = formatProcessor.WithBuildArgs(buildArgs)
+		}
+	}
 
 	return action(formatProcessor)
 }
 
-func (mopts *MatchingOptions) WithOutputDo(action func(outputPath string) error) error {
-	filename := string(mopts.Positional.InputFile)
-	return action(filename)
+func (mopts *MatchingOptions) WithOutputDo(inputPath string, action func(outputPath string) error) error {
+	return action(inputPath)
 }
 
 func exitCodeFromError(err error) (ExitCode, bool) {
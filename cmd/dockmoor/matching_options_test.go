@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
 	"github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestEmptyPredicates(t *testing.T) {
@@ -59,6 +65,8 @@ func applyPredicatesByName(fo *MatchingOptions, names ...string) {
 			fo.NamePredicates.FamiliarNames = []string{"a", "b"}
 		case equalsAnyString(pathPred, name):
 			fo.NamePredicates.Paths = []string{"a", "b"}
+		case equalsAnyString(stagePred, name):
+			fo.StagePredicates.Stages = []string{"a", "b"}
 		default:
 			panic(fmt.Sprintf("Unknown predicate name '%s'", name))
 		}
@@ -291,14 +299,33 @@ func TestUntaggedPredicateWhenUntaggedSet(t *testing.T) {
 	assert.IsType(t, expected, predicate)
 }
 
-//func TestOutdatedPredicateWhenOutdatedSet(t *testing.T) {
-//	fo := &MatchingOptions{}
-//	fo.TagPredicates.Outdated = true
-//
-//	predicate := fo.getPredicate()
-//
-//	assert.IsType(t, dockproc.OutdatedPredicateNew(), predicate)
-//}
+func TestOutdatedPredicateWhenOutdatedSet(t *testing.T) {
+	fo := &MatchingOptions{
+		mainOpts: mainOptionsTestNew().mainOptions,
+	}
+	fo.TagPredicates.Outdated = true
+	resolver := dockreftst.MockResolverNew()
+	fo.resolverProvider = func() dockref.Resolver {
+		return resolver
+	}
+
+	predicate, e := fo.getPredicate()
+	assert.Nil(t, e)
+
+	expected, e := dockproc.OutdatedPredicateNew(resolver, nil)
+	assert.Nil(t, e)
+	assert.IsType(t, expected, predicate)
+}
+
+func TestOutdatedPredicateWithoutResolverIsError(t *testing.T) {
+	fo := &MatchingOptions{
+		mainOpts: mainOptionsTestNew().mainOptions,
+	}
+	fo.TagPredicates.Outdated = true
+
+	_, e := fo.getPredicate()
+	assert.Error(t, e)
+}
 
 func TestLatestPredicateWhenLatestSet(t *testing.T) {
 	fo := &MatchingOptions{}
@@ -371,7 +398,7 @@ func TestAndPredicateWhenUnpinnedAndLatestSet(t *testing.T) {
 	assert.Equal(t, 2, matches)
 }
 
-var unimplemented = []string{outdatedPred}
+var unimplemented = []string{}
 
 func TestHelpContainsImplementedPredicates(t *testing.T) {
 	mo := MatchingOptions{}
@@ -403,3 +430,44 @@ func TestMatchingOptions_matchAndProcessFormatProcessorReturnsErrorForErrorIn(t
 	assert.Error(t, e)
 	assert.Nil(t, predicate)
 }
+
+func TestWithFormatProcessorDoProcessesFullStdin(t *testing.T) {
+	mainOptions := mainOptionsTestNew()
+	mainOptions.stdin = ioutil.NopCloser(strings.NewReader("FROM nginx"))
+	mainOptions.readableOpener = defaultReadableOpener(mainOptions.mainOptions)
+
+	mo := MatchingOptions{
+		mainOpts: mainOptions.mainOptions,
+	}
+	mo.Positional.InputFiles = []flags.Filename{"-"}
+
+	readAll := func(reader interface{}) string {
+		content, err := ioutil.ReadAll(reader.(io.Reader))
+		assert.Nil(t, err)
+		return string(content)
+	}
+
+	var validated string
+	processed := false
+	format := &FormatMock{}
+	format.OnName().Return("Mock")
+	format.OnValidateInput(mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		validated = readAll(args.Get(1))
+	})
+	format.OnProcess(mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		processed = true
+	})
+	mainOptions.FormatProvider().OnFormats().Return([]dockfmt.Format{format})
+
+	_, err := mo.WithInputDo(func(filePathInput string, fpInput io.Reader) (bool, error) {
+		return false, mo.WithFormatProcessorDo(filePathInput, fpInput, func(processor dockfmt.FormatProcessor) error {
+			return processor.Process(func(r dockref.Reference) (dockref.Reference, error) {
+				return r, nil
+			})
+		})
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "FROM nginx", validated)
+	assert.True(t, processed)
+}
@@ -0,0 +1,315 @@
+// Package bake implements the format of docker buildx bake files, docker-bake.hcl and docker-bake.json.
+// Images are referenced by the contexts, cache-from and args of targets and by the defaults of variables.
+package bake
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*bakeFormat)(nil)
+
+// ensure Document is implemented
+var _ dockfmt.Document = (*hclDocument)(nil)
+
+// blockTypes are the top level blocks of a bake file, other top level attributes are variables
+var blockTypes = map[string]struct{}{
+	"target":   {},
+	"group":    {},
+	"variable": {},
+	"function": {},
+}
+
+// dockerImageScheme is the prefix of contexts that reference an image, e.g. docker-image://alpine:3.18
+const dockerImageScheme = "docker-image://"
+
+type bakeFormat struct {
+}
+
+func (format *bakeFormat) Name() string {
+	return "bake"
+}
+
+func New() dockfmt.Format {
+	return newBakeFormat()
+}
+
+func newBakeFormat() *bakeFormat {
+	return new(bakeFormat)
+}
+
+func (format *bakeFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *bakeFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// a HCL file starts with an attribute or block, a JSON file with an object
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return validateJSON(content)
+	}
+	return validateHCL(content)
+}
+
+// isImageArg returns true for args and variables whose name ends with image, e.g. BASE_IMAGE
+func isImageArg(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), "image")
+}
+
+// cacheImage returns the text around the image of a cache-from entry, which is either an image
+// or a list of attributes like type=registry,ref=user/app:cache. ok is false for other types of cache.
+func cacheImage(value string) (prefix string, suffix string, ok bool) {
+	if !strings.Contains(value, "=") {
+		return "", "", true
+	}
+
+	start, end := -1, -1
+	offset := 0
+	for _, field := range strings.Split(value, ",") {
+		switch {
+		case strings.HasPrefix(field, "type=") && field != "type=registry":
+			return "", "", false
+		case strings.HasPrefix(field, "ref="):
+			start = offset + len("ref=")
+			end = offset + len(field)
+		}
+		offset += len(field) + 1
+	}
+	if start < 0 {
+		return "", "", false
+	}
+	return value[:start], value[end:], true
+}
+
+func targetInstruction(target string, key string) string {
+	return "target." + target + "." + key
+}
+
+func indexed(key string, i int) string {
+	return key + "[" + strconv.Itoa(i) + "]"
+}
+
+// hclImage is the image reference in a string of a HCL bake file, between Prefix and Suffix
+type hclImage struct {
+	str         token
+	prefix      string
+	suffix      string
+	instruction string
+}
+
+// hclDocument rewrites the image references of a HCL bake file
+type hclDocument struct {
+	lines  []string
+	images []hclImage
+}
+
+func validateHCL(content []byte) (*hclDocument, error) {
+	tokens, err := lex(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := 0
+	for _, b := range file.blocks {
+		if _, ok := blockTypes[b.typ]; !ok {
+			return nil, errors.Errorf("Unknown block %s in line %d", b.typ, b.line)
+		}
+		if b.typ == "target" {
+			targets++
+		}
+	}
+	if targets == 0 {
+		return nil, errors.Errorf("No targets found")
+	}
+
+	return &hclDocument{
+		lines:  strings.SplitAfter(string(content), "\n"),
+		images: hclImages(file),
+	}, nil
+}
+
+// hclImages returns the images of the targets and variables in document order
+func hclImages(file *body) []hclImage {
+	images := make([]hclImage, 0)
+	for _, b := range file.blocks {
+		if len(b.labels) != 1 {
+			continue
+		}
+		name := b.labels[0]
+
+		switch b.typ {
+		case "target":
+			for _, a := range b.body.attributes {
+				images = append(images, targetImages(name, a)...)
+			}
+		case "variable":
+			def := b.body.attribute("default")
+			if isImageArg(name) && def != nil && def.expression.kind == expressionString {
+				images = append(images, hclImage{str: def.expression.str, instruction: "variable." + name + ".default"})
+			}
+		}
+	}
+	return images
+}
+
+// targetImages returns the images of the attribute of target
+func targetImages(target string, a *attribute) []hclImage {
+	images := make([]hclImage, 0)
+	value := a.expression
+	switch {
+	case a.name == "contexts" && value.kind == expressionObject:
+		for i, key := range value.keys {
+			context := value.values[i]
+			if context.kind == expressionString && strings.HasPrefix(context.str.text, dockerImageScheme) {
+				images = append(images, hclImage{
+					str:         context.str,
+					prefix:      dockerImageScheme,
+					instruction: targetInstruction(target, "contexts."+key),
+				})
+			}
+		}
+	case a.name == "cache-from" && value.kind == expressionList:
+		for i, entry := range value.items {
+			instruction := targetInstruction(target, indexed("cache-from", i))
+			switch entry.kind {
+			case expressionString:
+				if prefix, suffix, ok := cacheImage(entry.str.text); ok {
+					images = append(images, hclImage{str: entry.str, prefix: prefix, suffix: suffix, instruction: instruction})
+				}
+			case expressionObject:
+				if ref := registryRef(entry); ref != nil {
+					images = append(images, hclImage{str: ref.str, instruction: instruction})
+				}
+			}
+		}
+	case a.name == "args" && value.kind == expressionObject:
+		for i, key := range value.keys {
+			arg := value.values[i]
+			if isImageArg(key) && arg.kind == expressionString {
+				images = append(images, hclImage{str: arg.str, instruction: targetInstruction(target, "args."+key)})
+			}
+		}
+	}
+	return images
+}
+
+// registryRef returns the ref of a cache-from object like { type = "registry", ref = "user/app:cache" }
+func registryRef(cache *expression) *expression {
+	var ref *expression
+	for i, key := range cache.keys {
+		value := cache.values[i]
+		switch {
+		case key == "type" && (value.kind != expressionString || value.str.text != "registry"):
+			return nil
+		case key == "ref" && value.kind == expressionString:
+			ref = value
+		}
+	}
+	return ref
+}
+
+func (document *hclDocument) Process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	err := document.process(log, w, imageNameProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+	return nil
+}
+
+// edit replaces the bytes between start and end of a line with text
+type edit struct {
+	line  int
+	start int
+	end   int
+	text  string
+}
+
+func (document *hclDocument) process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	edits := make([]edit, 0)
+	for _, image := range document.images {
+		str := image.str
+		if len(image.prefix)+len(image.suffix) >= len(str.text) {
+			continue
+		}
+		value := str.text[len(image.prefix) : len(str.text)-len(image.suffix)]
+		if strings.Contains(value, "$") || strings.Contains(value, "%{") {
+			log.Warnf("Skipping image %s, variable substitution is not supported", value)
+			continue
+		}
+
+		log.Infof("Found image %s", value)
+		ref, err := dockref.Parse(value)
+		if err != nil {
+			return err
+		}
+
+		start := str.index + len(image.prefix)
+		line := document.lines[str.line-1]
+		processed, err := imageNameProcessor(ref, dockfmt.Location{
+			Line:        str.line,
+			Column:      utf8.RuneCountInString(line[:start]) + 1,
+			Instruction: image.instruction,
+		})
+		if err != nil {
+			return err
+		}
+
+		formatted := processed.String()
+		if formatted == value {
+			continue
+		}
+
+		log.Infof("Pinning '%s' as '%s'", value, formatted)
+		edits = append(edits, edit{line: str.line, start: start, end: start + len(value), text: formatted})
+	}
+
+	lines := append([]string(nil), document.lines...)
+	// right to left, so the offsets of the remaining edits stay valid
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line > edits[j].line
+		}
+		return edits[i].start > edits[j].start
+	})
+	for _, e := range edits {
+		l := lines[e.line-1]
+		lines[e.line-1] = l[:e.start] + e.text + l[e.end:]
+	}
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,323 @@
+package bake
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const hclFile = `# docker-bake.hcl
+variable "BASE_IMAGE" {
+  default = "alpine:3.18"
+}
+
+variable "TAG" {
+  default = "latest"
+}
+
+group "default" {
+  targets = ["app"]
+}
+
+target "app" {
+  dockerfile = "Dockerfile"
+  contexts = {
+    base = "docker-image://debian:bookworm"
+    src  = "./src"
+    "build" = "target:build"
+  }
+  cache-from = ["user/app:cache", "type=registry,ref=user/app:buildcache,mode=max", "type=local,src=/tmp/cache"]
+  args = {
+    BASE_IMAGE = "golang:1.21" // builder
+    VERSION    = "1.0"
+  }
+  tags = ["user/app:${TAG}"]
+}
+`
+
+const jsonFile = `{
+  "variable": {
+    "BASE_IMAGE": {
+      "default": "alpine:3.18"
+    }
+  },
+  "group": {
+    "default": {
+      "targets": ["app"]
+    }
+  },
+  "target": {
+    "app": {
+      "contexts": {
+        "base": "docker-image://debian:bookworm",
+        "src": "./src"
+      },
+      "cache-from": ["user/app:cache", "type=registry,ref=user/app:buildcache,mode=max", "type=local,src=/tmp/cache"],
+      "args": {
+        "BASE_IMAGE": "golang:1.21",
+        "VERSION": "1.0"
+      }
+    }
+  }
+}
+`
+
+func TestBakeName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "bake", name)
+}
+
+func TestBakeFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestBakeFormatDockerfileIsInvalid(t *testing.T) {
+	file := `FROM alpine
+RUN echo "target" { }`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestBakeFormatComposeIsInvalid(t *testing.T) {
+	file := `services:
+  app:
+    image: nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestBakeFormatUnknownBlockIsInvalid(t *testing.T) {
+	file := `resource "aws_instance" "web" {
+  ami = "ami-123"
+}
+target "app" {}`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestBakeFormatWithoutTargetsIsInvalid(t *testing.T) {
+	file := `group "default" {
+  targets = ["app"]
+}`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestBakeFormatJSONWithUnknownKeyIsInvalid(t *testing.T) {
+	file := `{"target": {"app": {}}, "services": {}}`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestBakeFormatHCLIsValid(t *testing.T) {
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(hclFile), "anything")
+	assert.Nil(t, valid)
+}
+
+func TestBakeFormatJSONIsValid(t *testing.T) {
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(jsonFile), "anything")
+	assert.Nil(t, valid)
+}
+
+func TestBakeFormatSkipsExpressions(t *testing.T) {
+	file := `/* targets
+   of the project */
+TAG = "latest"
+
+function "tag" {
+  params = [name]
+  result = "user/${name}:${TAG}"
+}
+
+target "app" {
+  tags = [tag("app"), for v in ["a", "b"]: "user/app:${v}"]
+  args = {for k, v in {A = "b"}: k => upper(v)}
+  platforms = TAG == "latest" ? ["linux/amd64", "linux/arm64"] : ["linux/amd64"]
+  dockerfile-inline = <<EOT
+FROM alpine
+EOT
+  contexts = { base = "docker-image://alpine:3.18" }
+}
+`
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine:3.18"}, images)
+}
+
+func TestBakeCallsProcessorForEveryImage(t *testing.T) {
+	for _, file := range []string{hclFile, jsonFile} {
+		images := make([]string, 0)
+		_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+			images = append(images, r.Original())
+			return r, nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"alpine:3.18",
+			"debian:bookworm",
+			"user/app:cache",
+			"user/app:buildcache",
+			"golang:1.21",
+		}, images)
+	}
+}
+
+func TestBakeCallsProcessorForCacheObjects(t *testing.T) {
+	file := `target "app" {
+  cache-from = [
+    { type = "registry", ref = "user/app:cache" },
+    { type = "local", src = "/tmp/cache" },
+  ]
+}`
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user/app:cache"}, images)
+}
+
+func TestBakeSkipsSubstitutedImages(t *testing.T) {
+	file := `target "app" {
+  args = {
+    BASE_IMAGE = "${BASE_IMAGE}"
+  }
+  contexts = {
+    base = "docker-image://alpine:${ALPINE_VERSION}"
+  }
+}`
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, images)
+}
+
+func TestBakeReportsLocationsInHCL(t *testing.T) {
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, hclFile, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 3, Column: 14, Instruction: "variable.BASE_IMAGE.default"},
+		{Line: 17, Column: 28, Instruction: "target.app.contexts.base"},
+		{Line: 21, Column: 18, Instruction: "target.app.cache-from[0]"},
+		{Line: 21, Column: 54, Instruction: "target.app.cache-from[1]"},
+		{Line: 23, Column: 19, Instruction: "target.app.args.BASE_IMAGE"},
+	}, locations)
+}
+
+func TestBakeReportsLocationsInJSON(t *testing.T) {
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, jsonFile, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 4, Column: 19, Instruction: "variable.BASE_IMAGE.default"},
+		{Line: 15, Column: 33, Instruction: "target.app.contexts.base"},
+		{Line: 18, Column: 23, Instruction: "target.app.cache-from[0]"},
+		{Line: 18, Column: 59, Instruction: "target.app.cache-from[1]"},
+		{Line: 20, Column: 24, Instruction: "target.app.args.BASE_IMAGE"},
+	}, locations)
+}
+
+func TestBakeUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	for _, file := range []string{hclFile, jsonFile} {
+		out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+			return r, nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, file, out)
+	}
+}
+
+func TestBakeRewritesOnlyTheImages(t *testing.T) {
+	file := `target "app" {
+  contexts = { base = "docker-image://debian:bookworm", src = "./src" }
+  cache-from = ["user/app:cache", "type=registry,ref=user/app:buildcache,mode=max"]
+  args = { BASE_IMAGE = "golang:1.21" }
+}
+`
+	expected := `target "app" {
+  contexts = { base = "docker-image://debian:pinned", src = "./src" }
+  cache-from = ["user/app:pinned", "type=registry,ref=user/app:pinned,mode=max"]
+  args = { BASE_IMAGE = "golang:pinned" }
+}
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestBakeRewritesImagesInJSON(t *testing.T) {
+	file := `{"target": {"app": {
+  "contexts": {"base": "docker-image://debian:bookworm"},
+  "cache-from": ["type=registry,ref=user/app:buildcache,mode=max"]
+}}}`
+	expected := `{"target": {"app": {
+  "contexts": {"base": "docker-image://debian:pinned"},
+  "cache-from": ["type=registry,ref=user/app:pinned,mode=max"]
+}}}`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestBakePassProcessorErrors(t *testing.T) {
+	for _, file := range []string{hclFile, jsonFile} {
+		expected := errors.New("expected")
+		_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+			return r, expected
+		})
+
+		assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+	}
+}
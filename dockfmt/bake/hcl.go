@@ -0,0 +1,467 @@
+package bake
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The bake file is parsed with a small subset of HCL: blocks, attributes and the literal strings, lists and objects
+// of their values. Other expressions like function calls, templates and for expressions are skipped,
+// they cannot be evaluated without the variables and functions of the bake file.
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNewline
+	tokenIdent
+	tokenString
+	tokenPunctuation
+	// tokenOther are numbers, operators and heredocs, they are only skipped
+	tokenOther
+)
+
+// token of a bake file, the text of strings is the content between the quotes
+type token struct {
+	kind tokenKind
+	text string
+	// line is the 1-based line and index the byte index in that line of the text
+	line  int
+	index int
+}
+
+func (t token) is(punctuation string) bool {
+	return t.kind == tokenPunctuation && t.text == punctuation
+}
+
+type lexer struct {
+	content   string
+	offset    int
+	line      int
+	lineStart int
+	tokens    []token
+}
+
+var heredocPattern = regexp.MustCompile(`^<<-?([A-Za-z_][A-Za-z0-9_-]*)[ \t]*\r?\n`)
+
+// lex splits content into tokens, comments and whitespace other than newlines are removed
+func lex(content string) ([]token, error) {
+	l := &lexer{content: content, line: 1, tokens: make([]token, 0)}
+	for l.offset < len(l.content) {
+		err := l.next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l.tokens, nil
+}
+
+func (l *lexer) emit(kind tokenKind, start int, end int) {
+	l.tokens = append(l.tokens, token{
+		kind:  kind,
+		text:  l.content[start:end],
+		line:  l.line,
+		index: start - l.lineStart,
+	})
+	l.offset = end
+}
+
+// advance moves to end, counting the lines in between
+func (l *lexer) advance(end int) {
+	for ; l.offset < end; l.offset++ {
+		if l.content[l.offset] == '\n' {
+			l.line++
+			l.lineStart = l.offset + 1
+		}
+	}
+}
+
+func (l *lexer) next() error {
+	rest := l.content[l.offset:]
+	c := rest[0]
+	switch {
+	case c == ' ' || c == '\t' || c == '\r':
+		l.offset++
+	case c == '\n':
+		l.emit(tokenNewline, l.offset, l.offset+1)
+		l.line++
+		l.lineStart = l.offset
+	case c == '#' || strings.HasPrefix(rest, "//"):
+		end := strings.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		l.offset += end
+	case strings.HasPrefix(rest, "/*"):
+		end := strings.Index(rest, "*/")
+		if end < 0 {
+			return errors.Errorf("Unterminated comment in line %d", l.line)
+		}
+		l.advance(l.offset + end + 2)
+	case c == '"':
+		end, err := l.scanString(l.offset)
+		if err != nil {
+			return err
+		}
+		l.emit(tokenString, l.offset+1, end-1)
+		l.offset = end
+	case heredocPattern.MatchString(rest):
+		return l.heredoc()
+	case isIdentStart(c):
+		end := 1
+		for end < len(rest) && (isIdentStart(rest[end]) || isDigit(rest[end]) || rest[end] == '-') {
+			end++
+		}
+		l.emit(tokenIdent, l.offset, l.offset+end)
+	case isDigit(c):
+		end := 1
+		for end < len(rest) && (isIdentStart(rest[end]) || isDigit(rest[end]) || rest[end] == '.') {
+			end++
+		}
+		l.emit(tokenOther, l.offset, l.offset+end)
+	case strings.HasPrefix(rest, "=>") || strings.HasPrefix(rest, "=="):
+		l.emit(tokenOther, l.offset, l.offset+2)
+	case strings.IndexByte("{}[]()=,:", c) >= 0:
+		l.emit(tokenPunctuation, l.offset, l.offset+1)
+	case strings.IndexByte(".?!<>+-*/%&|", c) >= 0:
+		l.emit(tokenOther, l.offset, l.offset+1)
+	default:
+		return errors.Errorf("Unexpected character %q in line %d", c, l.line)
+	}
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scanString returns the offset after the closing quote of the string starting at offset.
+// Template sequences like ${var} may contain strings themselves.
+func (l *lexer) scanString(offset int) (int, error) {
+	for i := offset + 1; i < len(l.content); i++ {
+		switch c := l.content[i]; c {
+		case '\\':
+			i++
+		case '\n':
+			return 0, errors.Errorf("Unterminated string in line %d", l.line)
+		case '"':
+			return i + 1, nil
+		case '$', '%':
+			// $${ and %%{ are escaped
+			if strings.HasPrefix(l.content[i+1:], string(c)+"{") {
+				i += 2
+			} else if strings.HasPrefix(l.content[i+1:], "{") {
+				end, err := l.scanTemplate(i + 2)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			}
+		}
+	}
+	return 0, errors.Errorf("Unterminated string in line %d", l.line)
+}
+
+// scanTemplate returns the offset after the } that closes the template sequence starting at offset
+func (l *lexer) scanTemplate(offset int) (int, error) {
+	depth := 0
+	for i := offset; i < len(l.content); i++ {
+		switch l.content[i] {
+		case '\n':
+			return 0, errors.Errorf("Unterminated template in line %d", l.line)
+		case '"':
+			end, err := l.scanString(i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i + 1, nil
+			}
+			depth--
+		}
+	}
+	return 0, errors.Errorf("Unterminated template in line %d", l.line)
+}
+
+// heredoc skips the heredoc up to the end of the line with its delimiter
+func (l *lexer) heredoc() error {
+	start := l.line
+	header := heredocPattern.FindStringSubmatch(l.content[l.offset:])
+	end := l.offset + len(header[0])
+	for end < len(l.content) {
+		lineEnd := strings.IndexByte(l.content[end:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(l.content) - end
+		}
+		line := l.content[end : end+lineEnd]
+		if strings.TrimSpace(line) == header[1] {
+			l.tokens = append(l.tokens, token{kind: tokenOther, text: header[1], line: start, index: l.offset - l.lineStart})
+			l.advance(end + lineEnd)
+			return nil
+		}
+		end += lineEnd + 1
+	}
+	return errors.Errorf("Unterminated heredoc %s in line %d", header[1], start)
+}
+
+// body contains the attributes and blocks of a file or block
+type body struct {
+	attributes []*attribute
+	blocks     []*block
+}
+
+// attribute is name = expression
+type attribute struct {
+	name       string
+	line       int
+	expression *expression
+}
+
+// block is type "label" { body }, e.g. target "app" { ... }
+type block struct {
+	typ    string
+	labels []string
+	line   int
+	body   *body
+}
+
+// attribute returns the attribute with name, nil when the body has no such attribute
+func (b *body) attribute(name string) *attribute {
+	for _, a := range b.attributes {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type expressionKind int
+
+const (
+	expressionOther expressionKind = iota
+	expressionString
+	expressionList
+	expressionObject
+)
+
+// expression is a literal string, list or object, other expressions are not evaluated
+type expression struct {
+	kind expressionKind
+	// str is the token of a string
+	str token
+	// items of a list
+	items []*expression
+	// keys and values of an object
+	keys   []string
+	values []*expression
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse returns the top level body of the tokens
+func parse(tokens []token) (*body, error) {
+	p := &parser{tokens: tokens}
+	b, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, unexpected(t)
+	}
+	return b, nil
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokenNewline {
+		p.pos++
+	}
+}
+
+func unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return errors.Errorf("Unexpected end of file")
+	}
+	return errors.Errorf("Unexpected %s in line %d", t.text, t.line)
+}
+
+// body parses attributes and blocks up to the } of the block or the end of the file
+func (p *parser) body() (*body, error) {
+	b := &body{
+		attributes: make([]*attribute, 0),
+		blocks:     make([]*block, 0),
+	}
+	for {
+		p.skipNewlines()
+		name := p.peek()
+		if name.kind == tokenEOF || name.is("}") {
+			return b, nil
+		}
+		if name.kind != tokenIdent {
+			return nil, unexpected(name)
+		}
+		p.pos++
+
+		if p.peek().is("=") {
+			p.pos++
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if t := p.peek(); t.kind != tokenNewline && t.kind != tokenEOF && !t.is("}") {
+				return nil, unexpected(t)
+			}
+			b.attributes = append(b.attributes, &attribute{name: name.text, line: name.line, expression: value})
+			continue
+		}
+
+		nested := &block{typ: name.text, labels: make([]string, 0), line: name.line}
+		for p.peek().kind == tokenString || p.peek().kind == tokenIdent {
+			nested.labels = append(nested.labels, p.next().text)
+		}
+		if t := p.next(); !t.is("{") {
+			return nil, unexpected(t)
+		}
+		content, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); !t.is("}") {
+			return nil, unexpected(t)
+		}
+		nested.body = content
+		b.blocks = append(b.blocks, nested)
+	}
+}
+
+// expression parses the value of an attribute, list item or object entry
+func (p *parser) expression() (*expression, error) {
+	start := p.pos
+	var expr *expression
+	switch t := p.peek(); {
+	case t.kind == tokenString:
+		p.pos++
+		expr = &expression{kind: expressionString, str: t}
+	case t.is("["):
+		expr = p.list()
+	case t.is("{"):
+		expr = p.object()
+	}
+	if expr != nil && p.atEndOfExpression() {
+		return expr, nil
+	}
+
+	// anything else, e.g. function calls, operators or for expressions
+	p.pos = start
+	return &expression{kind: expressionOther}, p.skipExpression()
+}
+
+func (p *parser) atEndOfExpression() bool {
+	t := p.peek()
+	return t.kind == tokenEOF || t.kind == tokenNewline || t.is(",") || t.is("]") || t.is("}") || t.is(")")
+}
+
+// skipExpression skips the tokens up to the end of the expression
+func (p *parser) skipExpression() error {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenEOF:
+			if depth > 0 {
+				return unexpected(t)
+			}
+			return nil
+		case depth == 0 && p.atEndOfExpression():
+			return nil
+		case t.is("(") || t.is("[") || t.is("{"):
+			depth++
+		case t.is(")") || t.is("]") || t.is("}"):
+			depth--
+		}
+		p.pos++
+	}
+}
+
+// list parses [ item, ... ], nil when it is not a literal list, e.g. a for expression
+func (p *parser) list() *expression {
+	p.pos++
+	expr := &expression{kind: expressionList, items: make([]*expression, 0)}
+	for {
+		p.skipNewlines()
+		if p.peek().is("]") {
+			p.pos++
+			return expr
+		}
+		if t := p.peek(); t.kind == tokenIdent && t.text == "for" {
+			return nil
+		}
+		item, err := p.expression()
+		if err != nil {
+			return nil
+		}
+		expr.items = append(expr.items, item)
+		p.skipNewlines()
+		if p.peek().is(",") {
+			p.pos++
+		} else if !p.peek().is("]") {
+			return nil
+		}
+	}
+}
+
+// object parses { key = value, ... }, nil when it is not a literal object, e.g. a for expression
+func (p *parser) object() *expression {
+	p.pos++
+	expr := &expression{kind: expressionObject, keys: make([]string, 0), values: make([]*expression, 0)}
+	for {
+		p.skipNewlines()
+		if p.peek().is("}") {
+			p.pos++
+			return expr
+		}
+		key := p.next()
+		if (key.kind != tokenIdent && key.kind != tokenString) || key.text == "for" {
+			return nil
+		}
+		if separator := p.next(); !separator.is("=") && !separator.is(":") {
+			return nil
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil
+		}
+		expr.keys = append(expr.keys, key.text)
+		expr.values = append(expr.values, value)
+		if p.peek().is(",") {
+			p.pos++
+		} else if t := p.peek(); t.kind != tokenNewline && !t.is("}") {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package bake
+
+import (
+	"bytes"
+
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// validateJSON parses the JSON bake file, JSON is YAML, so the images are rewritten like in YAML formats
+func validateJSON(content []byte) (*yamlfmt.Document, error) {
+	_, root, err := yamlfmt.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not an object")
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if _, ok := blockTypes[key.Value]; !ok {
+			return nil, errors.Errorf("Unknown key %s in line %d", key.Value, key.Line)
+		}
+	}
+
+	targets := yamlfmt.MappingValue(root, "target")
+	if targets == nil || targets.Kind != yaml.MappingNode || len(targets.Content) == 0 {
+		return nil, errors.Errorf("No targets found")
+	}
+
+	images := make([]yamlfmt.Image, 0)
+	for i := 1; i < len(root.Content); i += 2 {
+		blocks := root.Content[i]
+		if blocks.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 1; j < len(blocks.Content); j += 2 {
+			name := blocks.Content[j-1].Value
+			switch root.Content[i-1].Value {
+			case "target":
+				images = append(images, jsonTargetImages(name, blocks.Content[j])...)
+			case "variable":
+				if def := yamlfmt.MappingValue(blocks.Content[j], "default"); isImageArg(name) && def != nil {
+					images = append(images, yamlfmt.Image{Node: def, Instruction: "variable." + name + ".default"})
+				}
+			}
+		}
+	}
+
+	return yamlfmt.DocumentNew(content, images), nil
+}
+
+// jsonTargetImages returns the images of the contexts, cache-from and args of target in document order
+func jsonTargetImages(name string, target *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	if target.Kind != yaml.MappingNode {
+		return images
+	}
+
+	for i := 1; i < len(target.Content); i += 2 {
+		key := target.Content[i-1].Value
+		value := target.Content[i]
+		switch {
+		case key == "contexts" && value.Kind == yaml.MappingNode:
+			for j := 1; j < len(value.Content); j += 2 {
+				images = append(images, yamlfmt.Image{
+					Node:        value.Content[j],
+					Prefix:      dockerImageScheme,
+					Instruction: targetInstruction(name, "contexts."+value.Content[j-1].Value),
+				})
+			}
+		case key == "cache-from" && value.Kind == yaml.SequenceNode:
+			for j, entry := range value.Content {
+				instruction := targetInstruction(name, indexed("cache-from", j))
+				switch entry.Kind {
+				case yaml.ScalarNode:
+					if prefix, suffix, ok := cacheImage(entry.Value); ok {
+						images = append(images, yamlfmt.Image{Node: entry, Prefix: prefix, Suffix: suffix, Instruction: instruction})
+					}
+				case yaml.MappingNode:
+					cacheType := yamlfmt.MappingValue(entry, "type")
+					if cacheType == nil || cacheType.Value == "registry" {
+						images = append(images, yamlfmt.Image{Node: yamlfmt.MappingValue(entry, "ref"), Instruction: instruction})
+					}
+				}
+			}
+		case key == "args" && value.Kind == yaml.MappingNode:
+			for j := 1; j < len(value.Content); j += 2 {
+				if arg := value.Content[j-1].Value; isImageArg(arg) {
+					images = append(images, yamlfmt.Image{Node: value.Content[j], Instruction: targetInstruction(name, "args."+arg)})
+				}
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,147 @@
+package circleci
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*circleciFormat)(nil)
+
+// executorKeys are the keys of configs and inline orbs that contain jobs or executors with a docker key
+var executorKeys = []string{"executors", "jobs"}
+
+type circleciFormat struct {
+}
+
+func (format *circleciFormat) Name() string {
+	return "circleci"
+}
+
+func New() dockfmt.Format {
+	return newCircleciFormat()
+}
+
+func newCircleciFormat() *circleciFormat {
+	return new(circleciFormat)
+}
+
+func (format *circleciFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *circleciFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	// docker-compose files also have a version, but define services instead of jobs
+	if yamlfmt.MappingValue(root, "version") == nil {
+		return nil, errors.Errorf("No version found")
+	}
+
+	found := false
+	for _, key := range []string{"executors", "jobs", "orbs"} {
+		node := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, key))
+		if node == nil {
+			continue
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, errors.Errorf("%s is not a mapping", key)
+		}
+		found = true
+
+		// .travis.yml files also have a version and jobs, but jobs contains sequences
+		if key == "orbs" {
+			continue
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			if yamlfmt.ResolveAlias(node.Content[i]).Kind != yaml.MappingNode {
+				return nil, errors.Errorf("%s %s is not a mapping", key, node.Content[i-1].Value)
+			}
+		}
+	}
+
+	if !found {
+		return nil, errors.Errorf("Neither jobs, executors nor orbs found")
+	}
+
+	return yamlfmt.DocumentNew(content, imageNodes(root)), nil
+}
+
+// imageNodes returns the docker images of executors and jobs, including those of inline orbs, in document order
+func imageNodes(root *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	for i := 1; i < len(root.Content); i += 2 {
+		key := root.Content[i-1].Value
+		value := yamlfmt.ResolveAlias(root.Content[i])
+
+		if key == "orbs" && value.Kind == yaml.MappingNode {
+			for j := 1; j < len(value.Content); j += 2 {
+				// orbs are either a reference like circleci/node@1.0 or defined inline
+				orb := yamlfmt.ResolveAlias(value.Content[j])
+				if orb.Kind != yaml.MappingNode {
+					continue
+				}
+				prefix := "orbs." + value.Content[j-1].Value + "."
+				for _, executorKey := range executorKeys {
+					images = append(images, executorImages(prefix+executorKey+".", yamlfmt.MappingValue(orb, executorKey))...)
+				}
+			}
+			continue
+		}
+
+		for _, executorKey := range executorKeys {
+			if key == executorKey {
+				images = append(images, executorImages(key+".", value)...)
+			}
+		}
+	}
+
+	return images
+}
+
+// executorImages returns the images of the docker key of each executor or job in executors
+func executorImages(prefix string, executors *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	executors = yamlfmt.ResolveAlias(executors)
+	if executors == nil || executors.Kind != yaml.MappingNode {
+		return images
+	}
+
+	for i := 1; i < len(executors.Content); i += 2 {
+		docker := yamlfmt.ResolveAlias(yamlfmt.MappingValue(executors.Content[i], "docker"))
+		if docker == nil || docker.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		name := executors.Content[i-1].Value
+		for j, container := range docker.Content {
+			images = append(images, yamlfmt.Image{
+				Node:        yamlfmt.MappingValue(container, "image"),
+				Instruction: prefix + name + ".docker[" + strconv.Itoa(j) + "].image",
+			})
+		}
+	}
+
+	return images
+}
@@ -0,0 +1,281 @@
+package circleci
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestCircleciName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "circleci", name)
+}
+
+func TestCircleciFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestCircleciFormatWithoutVersionIsInvalid(t *testing.T) {
+	file := `jobs:
+  build:
+    docker:
+      - image: golang`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestCircleciFormatComposeIsInvalid(t *testing.T) {
+	file := `version: "3"
+services:
+  web:
+    image: nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestCircleciFormatTravisIsInvalid(t *testing.T) {
+	file := `version: ~> 1.0
+language: go
+jobs:
+  include:
+    - script: make`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestCircleciFormatJobsSequenceIsInvalid(t *testing.T) {
+	file := `version: 2
+jobs:
+  - build`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestCircleciFormatConfigsAreValid(t *testing.T) {
+	files := map[string]string{
+		"jobs": `version: 2
+jobs:
+  build:
+    machine: true`,
+		"executors": `version: 2.1
+executors:
+  go:
+    docker:
+      - image: golang`,
+		"orbs": `version: 2.1
+orbs:
+  node: circleci/node@1.0.0`,
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Nil(t, valid)
+		})
+	}
+}
+
+func TestCircleciCallsProcessorForEveryImage(t *testing.T) {
+	file := `version: 2.1
+
+orbs:
+  node: circleci/node@1.0.0
+  inline:
+    executors:
+      default:
+        docker:
+          - image: alpine:3.9
+    jobs:
+      hello:
+        executor: default
+        docker:
+          - image: busybox:1.30
+        steps:
+          - run: echo hello
+
+executors:
+  go:
+    docker:
+      - image: golang:1.12
+        auth:
+          username: user
+
+jobs:
+  build:
+    docker:
+      - image: circleci/golang:1.12
+      - image: postgres:11
+        environment:
+          POSTGRES_USER: root
+    steps:
+      - checkout
+  test:
+    executor: go
+    steps:
+      - run: make test
+  deploy:
+    machine: true
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine:3.9", "busybox:1.30", "golang:1.12", "circleci/golang:1.12", "postgres:11"}, images)
+}
+
+func TestCircleciReportsLocations(t *testing.T) {
+	file := `version: 2.1
+orbs:
+  inline:
+    executors:
+      default:
+        docker:
+          - image: alpine
+jobs:
+  build:
+    docker:
+      - image: golang
+      - {image: "postgres"}`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 7, Column: 20, Instruction: "orbs.inline.executors.default.docker[0].image"},
+		{Line: 11, Column: 16, Instruction: "jobs.build.docker[0].image"},
+		{Line: 12, Column: 18, Instruction: "jobs.build.docker[1].image"},
+	}, locations)
+}
+
+func TestCircleciUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# a comment
+version: 2
+jobs:
+  build:
+    docker:
+      - image: golang   # trailing comment
+    steps:
+      - checkout
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestCircleciRewritesImagesInPlace(t *testing.T) {
+	file := `# a comment
+version: 2.1
+defaults: &defaults
+  docker:
+    - image: &go golang:1.12   # trailing comment
+executors:
+  go: *defaults
+jobs:
+  build:
+    <<: *defaults
+    steps:
+      - checkout
+  test:
+    docker:
+      - image: *go
+      - image: 'postgres'
+`
+	expected := `# a comment
+version: 2.1
+defaults: &defaults
+  docker:
+    - image: &go golang:pinned   # trailing comment
+executors:
+  go: *defaults
+jobs:
+  build:
+    <<: *defaults
+    steps:
+      - checkout
+  test:
+    docker:
+      - image: *go
+      - image: 'postgres:pinned'
+`
+	calls := 0
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		calls++
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, expected, out)
+}
+
+func TestCircleciSkipsParameters(t *testing.T) {
+	file := `version: 2.1
+executors:
+  node:
+    parameters:
+      tag:
+        type: string
+    docker:
+      - image: circleci/node:<< parameters.tag >>
+jobs:
+  build:
+    docker:
+      - image: golang`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"golang"}, images)
+}
+
+func TestCircleciPassProcessorErrors(t *testing.T) {
+	file := `version: 2
+jobs:
+  build:
+    docker:
+      - image: golang`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
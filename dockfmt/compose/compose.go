@@ -0,0 +1,103 @@
+package compose
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*composeFormat)(nil)
+
+type composeFormat struct {
+}
+
+func (format *composeFormat) Name() string {
+	return "docker-compose"
+}
+
+func New() dockfmt.Format {
+	return newComposeFormat()
+}
+
+func newComposeFormat() *composeFormat {
+	return new(composeFormat)
+}
+
+func (format *composeFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *composeFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*composeDocument, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	// v2, v3 and the compose specification all define the services below the "services" key,
+	// other YAML formats like .gitlab-ci.yml use a sequence there, if at all
+	services := yamlfmt.MappingValue(root, "services")
+	if services == nil {
+		return nil, errors.Errorf("No services found")
+	}
+	services = yamlfmt.ResolveAlias(services)
+	if services.Kind != yaml.MappingNode || len(services.Content) == 0 {
+		return nil, errors.Errorf("services is not a mapping of services")
+	}
+
+	for i := 1; i < len(services.Content); i += 2 {
+		service := yamlfmt.ResolveAlias(services.Content[i])
+		name := services.Content[i-1].Value
+		if service.Kind != yaml.MappingNode {
+			return nil, errors.Errorf("Service %s is not a mapping", name)
+		}
+
+		if yamlfmt.MappingValue(service, "image") == nil &&
+			yamlfmt.MappingValue(service, "build") == nil &&
+			yamlfmt.MappingValue(service, "extends") == nil {
+			return nil, errors.Errorf("Service %s has neither image, build nor extends", name)
+		}
+	}
+
+	file := &composeFile{filename: filepath.Clean(filename), content: content, services: services}
+	return &composeDocument{
+		Document: yamlfmt.DocumentNew(content, imageNodes(services)),
+		extended: extendsResolverNew(file).extendedImages(log, file),
+	}, nil
+}
+
+// imageNodes returns the nodes of all services' image keys in document order.
+// Images that services inherit with extends from the same file are processed with the service that defines them.
+func imageNodes(services *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	for i := 1; i < len(services.Content); i += 2 {
+		name := services.Content[i-1].Value
+		image := yamlfmt.MappingValue(services.Content[i], "image")
+		if image == nil {
+			continue
+		}
+		images = append(images, yamlfmt.Image{
+			Node:        image,
+			Instruction: "services." + name + ".image",
+		})
+	}
+
+	return images
+}
@@ -0,0 +1,361 @@
+package compose
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestComposeName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "docker-compose", name)
+}
+
+func TestComposeFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestComposeFormatDockerfileIsInvalid(t *testing.T) {
+	file := `FROM nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestComposeFormatWithoutServicesIsInvalid(t *testing.T) {
+	file := `version: "3"
+volumes:
+  data: {}`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestComposeFormatServicesSequenceIsInvalid(t *testing.T) {
+	file := `services:
+  - docker:dind
+job:
+  image: nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestComposeFormatServiceWithoutImageBuildOrExtendsIsInvalid(t *testing.T) {
+	file := `services:
+  web:
+    ports:
+      - 80:80`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestComposeFormatVersionsAreValid(t *testing.T) {
+	files := map[string]string{
+		"v2": `version: '2.4'
+services:
+  web:
+    image: nginx`,
+		"v3": `version: "3.8"
+services:
+  web:
+    image: nginx`,
+		"spec": `services:
+  web:
+    image: nginx`,
+		"build": `services:
+  web:
+    build: .`,
+		"extends": `services:
+  web:
+    extends:
+      file: common.yml
+      service: web`,
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Nil(t, valid)
+		})
+	}
+}
+
+func TestComposeReportsLocations(t *testing.T) {
+	file := `version: "3"
+services:
+  web:
+    image: nginx:1.15
+  db:
+    image: &db "postgres"
+  cache: {image: redis}`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 4, Column: 12, Instruction: "services.web.image"},
+		{Line: 6, Column: 17, Instruction: "services.db.image"},
+		{Line: 7, Column: 18, Instruction: "services.cache.image"},
+	}, locations)
+}
+
+func TestComposeCallsProcessorForEveryImage(t *testing.T) {
+	file := `version: "3"
+services:
+  web:
+    image: nginx:1.15
+  db:
+    image: postgres
+  app:
+    build: .
+  cache: {image: redis}`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx:1.15", "postgres", "redis"}, images)
+}
+
+func TestComposeCallsProcessorOnceForExtendedServices(t *testing.T) {
+	file := `services:
+  base:
+    image: nginx:1.15
+  web:
+    extends: base
+  admin:
+    extends:
+      service: base
+  worker:
+    extends:
+      file: common.yml
+      service: worker`
+
+	images := make([]string, 0)
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx:1.15"}, images)
+	assert.Equal(t, "services.base.image", locations[0].Instruction)
+}
+
+func TestComposeReportsImagesExtendedFromOtherFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmoor")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	common := `services:
+  base:
+    image: "alpine:3.9"
+  worker:
+    extends: base
+  cyclic:
+    extends:
+      file: common.yml
+      service: cyclic
+`
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "common.yml"), []byte(common), 0666))
+
+	file := `services:
+  db:
+    image: postgres:11
+  worker:
+    extends:
+      file: common.yml
+      service: worker
+  base:
+    extends:
+      file: ./common.yml
+      service: base
+  cyclic:
+    extends:
+      file: common.yml
+      service: cyclic
+  missing:
+    extends:
+      file: missing.yml
+      service: missing
+`
+	document, err := New().ValidateInput(log, strings.NewReader(file), filepath.Join(dir, "docker-compose.yml"))
+	assert.Nil(t, err)
+
+	images := make([]string, 0)
+	locations := make([]dockfmt.Location, 0)
+	buffer := bytes.NewBuffer(nil)
+	err = document.Process(log, buffer, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		locations = append(locations, location)
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"postgres:11", "alpine:3.9"}, images)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 3, Column: 12, Instruction: "services.db.image"},
+		{File: filepath.Join(dir, "common.yml"), Line: 3, Column: 13, Instruction: "services.base.image"},
+	}, locations)
+	assert.Equal(t, strings.Replace(file, "postgres:11", "postgres:pinned", 1), buffer.String())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "common.yml"))
+	assert.Nil(t, err)
+	assert.Equal(t, common, string(content))
+}
+
+func TestComposeUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# a comment
+version: "3"
+services:
+  web:
+    image: nginx   # trailing comment
+    ports:
+      - "80:80"
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestComposeRewritesImagesInPlace(t *testing.T) {
+	file := `# a comment
+version: "3"
+services:
+  web:
+    ports:
+      - "80:80"
+    image: nginx   # trailing comment
+  db:
+    image: "postgres:11"
+  cache:
+    image: 'redis'
+  queue: {image: rabbitmq, restart: always}
+`
+	expected := `# a comment
+version: "3"
+services:
+  web:
+    ports:
+      - "80:80"
+    image: nginx:pinned   # trailing comment
+  db:
+    image: "postgres:pinned"
+  cache:
+    image: 'redis:pinned'
+  queue: {image: rabbitmq:pinned, restart: always}
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestComposeRewritesAnchorsOnce(t *testing.T) {
+	file := `x-base: &base
+  image: &img nginx
+  restart: always
+services:
+  web:
+    <<: *base
+  other:
+    image: *img
+`
+	expected := `x-base: &base
+  image: &img nginx:pinned
+  restart: always
+services:
+  web:
+    <<: *base
+  other:
+    image: *img
+`
+	calls := 0
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		calls++
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, expected, out)
+}
+
+func TestComposeSkipsVariables(t *testing.T) {
+	file := `services:
+  web:
+    image: ${IMAGE:-nginx}
+  db:
+    image: postgres`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"postgres"}, images)
+}
+
+func TestComposeInvalidImageReported(t *testing.T) {
+	file := `services:
+  web:
+    image: nginx:a:b`
+
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Error(t, err)
+}
+
+func TestComposePassProcessorErrors(t *testing.T) {
+	file := `services:
+  web:
+    image: nginx`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
@@ -0,0 +1,210 @@
+package compose
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is a parsed compose file whose services can be extended
+type composeFile struct {
+	filename string
+	content  []byte
+	services *yaml.Node
+}
+
+// extendedImage is the image of a service extended from another file, it is reported with the location in that file
+type extendedImage struct {
+	value    string
+	service  string
+	location dockfmt.Location
+}
+
+// ensure Document is implemented
+var _ dockfmt.Document = (*composeDocument)(nil)
+
+// composeDocument rewrites the images of a compose file and reports the images its services extend from other files
+type composeDocument struct {
+	*yamlfmt.Document
+	extended []extendedImage
+}
+
+func (document *composeDocument) Process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	err := document.Document.Process(log, w, imageNameProcessor)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range document.extended {
+		if strings.Contains(image.value, "$") {
+			log.Warnf("Skipping image %s, variable substitution is not supported", image.value)
+			continue
+		}
+
+		log.Infof("Found image %s of service %s in %s", image.value, image.service, image.location.File)
+		ref, err := dockref.Parse(image.value)
+		if err != nil {
+			return dockfmt.FormatErrorNew(err)
+		}
+
+		processed, err := imageNameProcessor(ref, image.location)
+		if err != nil {
+			return dockfmt.FormatErrorNew(err)
+		}
+
+		if processed.String() != image.value {
+			log.Warnf("Not rewriting image %s of service %s as '%s', it is defined in %s", image.value, image.service, processed.String(), image.location.File)
+		}
+	}
+
+	return nil
+}
+
+// extendsResolver follows extends of services to the service defining the image, also into other files
+type extendsResolver struct {
+	files map[string]*composeFile
+}
+
+func extendsResolverNew(file *composeFile) *extendsResolver {
+	return &extendsResolver{
+		files: map[string]*composeFile{file.filename: file},
+	}
+}
+
+// extendedImages returns the images of the services of file that extend a service of another file.
+// Images extended from services of the same file are processed with the service that defines them.
+// Extends that cannot be resolved, e.g. because the file does not exist, are skipped with a warning.
+func (resolver *extendsResolver) extendedImages(log logrus.FieldLogger, file *composeFile) []extendedImage {
+	type key struct {
+		filename string
+		node     *yaml.Node
+	}
+	seen := make(map[key]struct{})
+
+	images := make([]extendedImage, 0)
+	for i := 1; i < len(file.services.Content); i += 2 {
+		name := file.services.Content[i-1].Value
+		if _, _, ok := extendsOf(file.services.Content[i]); !ok {
+			continue
+		}
+
+		defining, definingName, image, err := resolver.definingService(file, name)
+		if err != nil {
+			log.Warnf("Cannot resolve extends of service %s: %s", name, err.Error())
+			continue
+		}
+		image = yamlfmt.ResolveAlias(image)
+		if defining == file || image == nil || image.Kind != yaml.ScalarNode {
+			continue
+		}
+		if _, ok := seen[key{defining.filename, image}]; ok {
+			continue
+		}
+		seen[key{defining.filename, image}] = struct{}{}
+
+		images = append(images, extendedImage{
+			value:   image.Value,
+			service: name,
+			location: dockfmt.Location{
+				File:        defining.filename,
+				Line:        image.Line,
+				Column:      yamlfmt.ValueColumn(defining.content, image),
+				Instruction: "services." + definingName + ".image",
+			},
+		})
+	}
+
+	return images
+}
+
+// definingService follows extends of the service name in file until a service with an image.
+// It returns the file, the name and the image of that service, the image is nil when no service has one.
+func (resolver *extendsResolver) definingService(file *composeFile, name string) (*composeFile, string, *yaml.Node, error) {
+	visited := make(map[string]struct{})
+	for {
+		key := file.filename + ":" + name
+		if _, ok := visited[key]; ok {
+			return nil, "", nil, errors.Errorf("service %s in %s extends itself", name, file.filename)
+		}
+		visited[key] = struct{}{}
+
+		service := yamlfmt.MappingValue(file.services, name)
+		if service == nil {
+			return nil, "", nil, errors.Errorf("no service %s in %s", name, file.filename)
+		}
+		if image := yamlfmt.MappingValue(service, "image"); image != nil {
+			return file, name, image, nil
+		}
+
+		extendedName, extendedFile, ok := extendsOf(service)
+		if !ok {
+			return file, name, nil, nil
+		}
+		if extendedFile != "" {
+			var err error
+			file, err = resolver.file(filepath.Join(filepath.Dir(file.filename), extendedFile))
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+		name = extendedName
+	}
+}
+
+// file returns the parsed compose file filename, files are read once
+func (resolver *extendsResolver) file(filename string) (*composeFile, error) {
+	if file, ok := resolver.files[filename]; ok {
+		return file, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	_, root, err := yamlfmt.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", filename)
+	}
+
+	services := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, "services"))
+	if services == nil || services.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("no services found in %s", filename)
+	}
+
+	file := &composeFile{filename: filename, content: content, services: services}
+	resolver.files[filename] = file
+	return file, nil
+}
+
+// extendsOf returns the name and the file of the service extended by service, the file is empty for services
+// of the same file. extends is either the name of the service or a mapping with service and file.
+func extendsOf(service *yaml.Node) (name string, file string, ok bool) {
+	extends := yamlfmt.ResolveAlias(yamlfmt.MappingValue(service, "extends"))
+	if extends == nil {
+		return "", "", false
+	}
+
+	if extends.Kind == yaml.ScalarNode {
+		return extends.Value, "", extends.Value != ""
+	}
+
+	serviceNode := yamlfmt.ResolveAlias(yamlfmt.MappingValue(extends, "service"))
+	if serviceNode == nil || serviceNode.Kind != yaml.ScalarNode || serviceNode.Value == "" {
+		return "", "", false
+	}
+	fileNode := yamlfmt.ResolveAlias(yamlfmt.MappingValue(extends, "file"))
+	if fileNode != nil && fileNode.Kind == yaml.ScalarNode {
+		file = fileNode.Value
+	}
+	return serviceNode.Value, file, true
+}
@@ -0,0 +1,321 @@
+package dockerfile
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// globalArg is an ARG before the first FROM, its value can be used in FROM instructions
+type globalArg struct {
+	name  string
+	value string
+	set   bool
+	// inFile is true when the value is the literal default in the Dockerfile, which can be rewritten
+	inFile bool
+	// word and offset of the default value, inside quotes
+	word   word
+	offset int
+}
+
+// position returns the position of the default value
+func (arg *globalArg) position() position {
+	return arg.word.positions[arg.offset]
+}
+
+var singleVariable = regexp.MustCompile(`^\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))$`)
+
+// variableName returns the name of the variable when word only consists of one variable, e.g. ${BASE}
+func variableName(word string) string {
+	match := singleVariable.FindStringSubmatch(word)
+	if match == nil {
+		return ""
+	}
+	return match[1] + match[2]
+}
+
+var tagVariable = regexp.MustCompile(`^([^$@]+:)\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))$`)
+
+var variableReference = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// argOfFrom returns the name of the ARG when the image of FROM is an ARG, e.g. ${BASE}, or an image name with
+// an ARG as tag, e.g. alpine:${TAG}. The prefix is the image name with the colon in front of the tag, or empty.
+func argOfFrom(image string) (name string, prefix string) {
+	if name := variableName(image); name != "" {
+		return name, ""
+	}
+	match := tagVariable.FindStringSubmatch(image)
+	if match == nil {
+		return "", ""
+	}
+	return match[2] + match[3], match[1]
+}
+
+func unquote(word string) (string, int) {
+	if len(word) >= 2 && (word[0] == '"' || word[0] == '\'') && word[len(word)-1] == word[0] {
+		return word[1 : len(word)-1], 1
+	}
+	return word, 0
+}
+
+// globalArgs returns the ARGs before the first FROM, values of buildArgs replace the defaults of declared ARGs
+func (document *dockerfileDocument) globalArgs(log logrus.FieldLogger) map[string]*globalArg {
+	args := make(map[string]*globalArg)
+	lex := shell.NewLex(document.result.EscapeToken)
+
+	for _, node := range document.result.AST.Children {
+		if node.Value == "from" {
+			break
+		}
+		if node.Value != "arg" {
+			continue
+		}
+
+		for word := node.Next; word != nil; word = word.Next {
+			arg := &globalArg{name: word.Value}
+			if previous, ok := args[word.Value]; ok {
+				// declaring the ARG again keeps the value
+				arg = previous
+			}
+			if i := strings.Index(word.Value, "="); i >= 0 {
+				arg = &globalArg{}
+				arg.name = word.Value[:i]
+				raw := word.Value[i+1:]
+				value, err := lex.ProcessWordWithMap(raw, argValues(args))
+				if err != nil {
+					log.Warnf("Cannot evaluate ARG %s: %s", arg.name, err.Error())
+				}
+				unquoted, _ := unquote(raw)
+				arg.value = value
+				arg.set = true
+				var found bool
+				arg.word, arg.offset, found = document.locateArgValue(node, arg.name, raw)
+				// only literal values can be rewritten
+				arg.inFile = err == nil && value == unquoted && found
+			}
+
+			if value, ok := document.buildArgs[arg.name]; ok {
+				arg.value = value
+				arg.set = true
+				arg.inFile = false
+			}
+			args[arg.name] = arg
+		}
+	}
+
+	return args
+}
+
+// locateArgValue returns the word name=raw of node and the offset of the unquoted value in the word
+func (document *dockerfileDocument) locateArgValue(node *parser.Node, name string, raw string) (word, int, bool) {
+	_, quote := unquote(raw)
+	for _, w := range document.words(node) {
+		if w.value == name+"="+raw {
+			return w, len(name) + 1 + quote, true
+		}
+	}
+	return word{}, 0, false
+}
+
+func argValues(args map[string]*globalArg) map[string]string {
+	values := make(map[string]string)
+	for name, arg := range args {
+		if arg.set {
+			values[name] = arg.value
+		}
+	}
+	return values
+}
+
+// processArgs calls imageNameProcessor for the default values of ARGs that are used as the image or the tag of FROM.
+// The processed values are returned by the name of the ARG, references in FROM are not rewritten.
+// An ARG is processed once, when several FROMs use it, the location has the stage and platform of the first FROM.
+// ARGs used in different ways, e.g. as the tag of different images, and tags of ARGs declared again in a stage
+// are not processed, because the rewritten default would change the other uses.
+func (document *dockerfileDocument) processArgs(log logrus.FieldLogger, state *processState, imageNameProcessor dockfmt.LocatedImageNameProcessor) (map[string]string, error) {
+	args := state.args
+	processed := make(map[string]string)
+	stageArgs := document.stageArgs()
+
+	// in the order of the ARGs, with the first FROM using the ARG
+	candidates := make([]*globalArg, 0)
+	froms := make(map[string]*parser.Node)
+	prefixes := make(map[string]string)
+	conflicting := make(map[string]bool)
+	stages := make(map[string]struct{})
+	for _, node := range document.result.AST.Children {
+		if node.Value != "from" || node.Next == nil {
+			continue
+		}
+		name, prefix := argOfFrom(node.Next.Value)
+		if name == "" {
+			for _, match := range variableReference.FindAllStringSubmatch(node.Next.Value, -1) {
+				conflicting[match[1]] = true
+			}
+		}
+		arg, ok := args[name]
+		isStage := false
+		if ok && prefix == "" {
+			_, isStage = stages[strings.ToLower(arg.value)]
+		}
+		if stage := stageName(node); stage != "" {
+			stages[strings.ToLower(stage)] = struct{}{}
+		}
+		if !ok || !arg.inFile || isStage {
+			continue
+		}
+		if _, ok := stageArgs[arg.name]; ok && prefix != "" {
+			conflicting[arg.name] = true
+		}
+		if _, ok := froms[arg.name]; !ok {
+			froms[arg.name] = node
+			prefixes[arg.name] = prefix
+			candidates = append(candidates, arg)
+		} else if prefixes[arg.name] != prefix {
+			conflicting[arg.name] = true
+		}
+	}
+
+	used := make([]*globalArg, 0)
+	for _, arg := range candidates {
+		if conflicting[arg.name] {
+			log.Infof("Not rewriting ARG %s, it is used in several ways", arg.name)
+			continue
+		}
+		used = append(used, arg)
+	}
+	sortArgs(used)
+
+	for _, arg := range used {
+		prefix := prefixes[arg.name]
+		image := prefix + arg.value
+		log.Infof("Found image %s in ARG %s", image, arg.name)
+		ref, err := dockref.Parse(image)
+		if err != nil {
+			return nil, err
+		}
+
+		location := document.location(arg.word, arg.offset)
+		location.Instruction = "ARG"
+		location.Stage = stageName(froms[arg.name])
+		location.Platform = state.platforms[froms[arg.name]]
+		result, err := imageNameProcessor(ref, location)
+		if err != nil {
+			return nil, err
+		}
+
+		formatted := result.String()
+		if !strings.HasPrefix(formatted, prefix) {
+			return nil, errors.Errorf("Cannot rewrite image %s as '%s', only the tag is the default of ARG %s", image, formatted, arg.name)
+		}
+		value := strings.TrimPrefix(formatted, prefix)
+		if value != arg.value {
+			log.Infof("Pinning '%s' as '%s'", arg.value, value)
+		}
+		processed[arg.name] = value
+	}
+
+	return processed, nil
+}
+
+// stageArgs returns the names of the ARGs declared in build stages, after the first FROM
+func (document *dockerfileDocument) stageArgs() map[string]struct{} {
+	names := make(map[string]struct{})
+	inStage := false
+	for _, node := range document.result.AST.Children {
+		if node.Value == "from" {
+			inStage = true
+		}
+		if !inStage || node.Value != "arg" {
+			continue
+		}
+		for word := node.Next; word != nil; word = word.Next {
+			names[strings.SplitN(word.Value, "=", 2)[0]] = struct{}{}
+		}
+	}
+	return names
+}
+
+// sortArgs sorts args by the position of their values
+func sortArgs(args []*globalArg) {
+	sort.Slice(args, func(i, j int) bool {
+		pi, pj := args[i].position(), args[j].position()
+		if pi.line != pj.line {
+			return pi.line < pj.line
+		}
+		return pi.index < pj.index
+	})
+}
+
+// argLines returns the lines of the ARG node with the processed values, nil when nothing changed
+func (document *dockerfileDocument) argLines(node *parser.Node, args map[string]*globalArg, processed map[string]string) []string {
+	start := node.StartLine
+	end := endLineOfNode(node)
+
+	edits := make([]edit, 0)
+	for word := node.Next; word != nil; word = word.Next {
+		name := strings.SplitN(word.Value, "=", 2)[0]
+		arg, ok := args[name]
+		value, isProcessed := processed[name]
+		if ok && isProcessed && value != arg.value && arg.position().line >= start && arg.position().line <= end {
+			edits = append(edits, replacement(arg.word, arg.offset, len(arg.value), value)...)
+		}
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+
+	return document.applyEdits(node, edits)
+}
+
+// expandFrom returns the image of FROM with the global ARGs substituted
+func (document *dockerfileDocument) expandFrom(image string, args map[string]*globalArg) (string, error) {
+	lex := shell.NewLex(document.result.EscapeToken)
+	return lex.ProcessWordWithMap(image, argValues(args))
+}
+
+// processSubstitutedFrom calls imageNameProcessor for FROM images that use ARGs.
+// Images and tags that are the literal value of an ARG are already processed, other images cannot be rewritten,
+// changing them is an error.
+func (document *dockerfileDocument) processSubstitutedFrom(log logrus.FieldLogger, node *parser.Node, state *processState, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	from := node.Next.Value
+	if name, _ := argOfFrom(from); name != "" {
+		if _, ok := state.argImages[name]; ok {
+			return nil
+		}
+	}
+
+	image, err := document.expandFrom(from, state.args)
+	var ref dockref.Reference
+	if err == nil {
+		ref, err = dockref.Parse(image)
+	}
+	if err != nil {
+		log.Warnf("Skipping image %s, the ARGs cannot be resolved", from)
+		return nil
+	}
+	if state.isStage(image) {
+		log.Infof("Skipping %s, it is the build stage %s", from, image)
+		return nil
+	}
+	log.Infof("Found image %s as %s", from, image)
+
+	location := document.locationOfFrom(node)
+	location.Platform = state.platforms[node]
+	processed, err := imageNameProcessor(ref, location)
+	if err != nil {
+		return err
+	}
+
+	if processed.String() != image {
+		return errors.Errorf("Cannot rewrite image %s in line %d as '%s', only the default of an ARG used as the image or its tag can be rewritten", from, node.StartLine, processed.String())
+	}
+	return nil
+}
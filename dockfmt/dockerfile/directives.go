@@ -0,0 +1,129 @@
+package dockerfile
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+)
+
+// directive is a parser directive at the top of the Dockerfile, e.g. # syntax=docker/dockerfile:1
+type directive struct {
+	// name is the lower case name of the directive
+	name  string
+	value string
+	// line and index of the value
+	line  int
+	index int
+}
+
+const utf8bom = "\ufeff"
+
+var directivePattern = regexp.MustCompile(`^#[ \t]*([A-Za-z][A-Za-z0-9]*)[ \t]*=[ \t]*(\S*)[ \t]*$`)
+
+// parseDirectives returns the parser directives, the comments of the form # name=value before any other line
+func parseDirectives(lines []string) []directive {
+	directives := make([]directive, 0)
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		bom := 0
+		if i == 0 && strings.HasPrefix(line, utf8bom) {
+			bom = len(utf8bom)
+		}
+		match := directivePattern.FindStringSubmatchIndex(line[bom:])
+		if match == nil {
+			break
+		}
+		directives = append(directives, directive{
+			name:  strings.ToLower(line[bom+match[2] : bom+match[3]]),
+			value: line[bom+match[4] : bom+match[5]],
+			line:  i + 1,
+			index: bom + match[4],
+		})
+	}
+	return directives
+}
+
+// findDirective returns the directive with name, nil when it is not declared
+func findDirective(directives []directive, name string) *directive {
+	for i := range directives {
+		if directives[i].name == name {
+			return &directives[i]
+		}
+	}
+	return nil
+}
+
+// escapeToken returns the escape character declared by the escape directive, \ by default
+func escapeToken(directives []directive) byte {
+	if escape := findDirective(directives, "escape"); escape != nil && escape.value == "`" {
+		return '`'
+	}
+	return '\\'
+}
+
+// parserInput returns the content for the parser. The parser only accepts the escape directive as first directive,
+// so it is moved to the top, and the bodies of heredocs are removed, they are not instructions.
+// The number of lines is kept, so the lines of the instructions stay the same.
+func parserInput(lines []string, directives []directive) string {
+	input := append([]string(nil), lines...)
+
+	if escape := findDirective(directives, "escape"); escape != nil && escape.line != directives[0].line {
+		first := directives[0].line
+		input[first-1], input[escape.line-1] = input[escape.line-1], input[first-1]
+	}
+
+	for _, line := range heredocBodies(lines, escapeToken(directives)) {
+		input[line-1] = lineEnding(input[line-1])
+	}
+
+	return strings.Join(input, "")
+}
+
+// lineEnding returns the newline at the end of line
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// processSyntax calls imageNameProcessor for the frontend image of the syntax directive.
+// It returns the line of the directive with the processed image, an empty string when nothing changed.
+func (document *dockerfileDocument) processSyntax(log logrus.FieldLogger, imageNameProcessor dockfmt.LocatedImageNameProcessor) (string, error) {
+	syntax := findDirective(document.directives, "syntax")
+	if syntax == nil || syntax.value == "" {
+		return "", nil
+	}
+
+	ref, err := dockref.Parse(syntax.value)
+	if err != nil {
+		log.Warnf("Skipping syntax %s, it is not an image reference", syntax.value)
+		return "", nil
+	}
+	log.Infof("Found frontend image %s", syntax.value)
+
+	line := document.lines[syntax.line-1]
+	processed, err := imageNameProcessor(ref, dockfmt.Location{
+		Line:        syntax.line,
+		Column:      utf8.RuneCountInString(strings.TrimPrefix(line[:syntax.index], utf8bom)) + 1,
+		Instruction: "# syntax",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	formatted := processed.String()
+	if formatted == syntax.value {
+		return "", nil
+	}
+	log.Infof("Pinning '%s' as '%s'", syntax.value, formatted)
+
+	return line[:syntax.index] + formatted + line[syntax.index+len(syntax.value):], nil
+}
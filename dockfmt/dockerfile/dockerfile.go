@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"path/filepath"
 	"reflect"
 	"strings"
 
@@ -20,19 +21,54 @@ func init() {
 	dockfmt.RegisterFormat(New())
 }
 
-// ensure Format is implemented
+// ensure Format and Document are implemented
 var _ dockfmt.Format = (*dockerfileFormat)(nil)
+var _ dockfmt.FilenameFormat = (*dockerfileFormat)(nil)
+var _ dockfmt.Document = (*dockerfileDocument)(nil)
+var _ dockfmt.BuildArgsDocument = (*dockerfileDocument)(nil)
 
 type dockerfileFormat struct {
-	lines         []string
-	result        *parser.Result
 	parseFunction func(rwc io.Reader) (*parser.Result, error)
 }
 
+// dockerfileDocument is a parsed Dockerfile, the original lines are kept to preserve the formatting
+type dockerfileDocument struct {
+	lines      []string
+	result     *parser.Result
+	directives []directive
+	buildArgs  map[string]string
+}
+
+// processState is the state of the instructions processed so far
+type processState struct {
+	// stage is the name of the current build stage
+	stage string
+	// stages are the lower case names of the stages declared so far, references to them are not images
+	stages map[string]struct{}
+	// args are the global ARGs
+	args map[string]*globalArg
+	// argImages are the processed values of ARGs used as image or tag of FROM
+	argImages map[string]string
+	// platforms are the platforms of the FROM instructions
+	platforms map[*parser.Node]string
+}
+
 func (format *dockerfileFormat) Name() string {
 	return "Dockerfile"
 }
 
+// MatchesFilename returns true for Dockerfile and Containerfile, also with a suffix like Dockerfile.prod or a prefix
+// like api.Dockerfile. Names are case insensitive.
+func (format *dockerfileFormat) MatchesFilename(filename string) bool {
+	name := strings.ToLower(filepath.Base(filename))
+	for _, base := range []string{"dockerfile", "containerfile"} {
+		if name == base || strings.HasPrefix(name, base+".") || strings.HasSuffix(name, "."+base) {
+			return true
+		}
+	}
+	return false
+}
+
 func New() dockfmt.Format {
 	return newDockerfileFormat()
 }
@@ -59,42 +95,41 @@ func dockerfileFormatSplitFunc(data []byte, atEOF bool) (advance int, token []by
 	return 0, nil, nil
 }
 
-func (format *dockerfileFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
-	err := format.validateInput(log, reader, filename)
+func (format *dockerfileFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
 	if err != nil {
-		return dockfmt.FormatErrorNew(err)
+		return nil, dockfmt.FormatErrorNew(err)
 	}
 
-	return nil
+	return document, nil
 }
 
-func (format *dockerfileFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+func (format *dockerfileFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*dockerfileDocument, error) {
 	scanner := bufio.NewScanner(reader)
 	var split bufio.SplitFunc = dockerfileFormatSplitFunc
 
-	full := ""
 	lines := make([]string, 0)
 	scanner.Split(split)
 	for scanner.Scan() {
 		line := scanner.Text()
 		lines = append(lines, line)
-		full += line
 	}
 
-	result, err := format.parseFunction(strings.NewReader(full))
+	directives := parseDirectives(lines)
+	result, err := format.parseFunction(strings.NewReader(parserInput(lines, directives)))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// the parser will just ignore "unknown" commands in the Dockerfile but not report any error
 	for _, cmd := range result.AST.Children {
 		if _, ok := command.Commands[cmd.Value]; !ok {
-			return errors.Errorf("Unknown command %s", cmd.Value)
+			return nil, errors.Errorf("Unknown command %s", cmd.Value)
 		}
 	}
 
 	if result.AST.Children == nil {
-		return errors.Errorf("No commands found")
+		return nil, errors.Errorf("No commands found")
 	}
 
 	// contains at least one FROM command
@@ -106,13 +141,14 @@ func (format *dockerfileFormat) validateInput(log logrus.FieldLogger, reader io.
 	}
 
 	if !containsFrom {
-		return errors.Errorf("No FROM command found")
+		return nil, errors.Errorf("No FROM command found")
 	}
 
-	format.lines = lines
-	format.result = result
-
-	return nil
+	return &dockerfileDocument{
+		lines:      lines,
+		result:     result,
+		directives: directives,
+	}, nil
 }
 
 func saveFlush(log logrus.FieldLogger, writer *bufio.Writer) {
@@ -122,8 +158,21 @@ func saveFlush(log logrus.FieldLogger, writer *bufio.Writer) {
 	}
 }
 
-func (format *dockerfileFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
-	err := format.process(log, reader, w, imageNameProcessor)
+// isStage returns true when name refers to a stage declared before, names of stages are case insensitive
+func (state *processState) isStage(name string) bool {
+	_, ok := state.stages[strings.ToLower(name)]
+	return ok
+}
+
+// WithBuildArgs returns a copy of the document that uses buildArgs instead of the defaults of global ARGs
+func (document *dockerfileDocument) WithBuildArgs(buildArgs map[string]string) dockfmt.Document {
+	cpy := *document
+	cpy.buildArgs = buildArgs
+	return &cpy
+}
+
+func (document *dockerfileDocument) Process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	err := document.process(log, w, imageNameProcessor)
 	if err != nil {
 		return dockfmt.FormatErrorNew(err)
 	}
@@ -131,14 +180,35 @@ func (format *dockerfileFormat) Process(log logrus.FieldLogger, reader io.Reader
 	return nil
 }
 
-func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+func (document *dockerfileDocument) process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
 	result := new(multierror.Error)
 	writer := bufio.NewWriter(w)
 
 	defer saveFlush(log, writer)
 
-	root := format.result.AST
-	lines := format.lines
+	root := document.result.AST
+	lines := document.lines
+
+	syntaxLine, err := document.processSyntax(log, imageNameProcessor)
+	if err != nil {
+		return err
+	}
+	if syntaxLine != "" {
+		lines = append([]string(nil), lines...)
+		lines[findDirective(document.directives, "syntax").line-1] = syntaxLine
+	}
+
+	state := &processState{
+		stages: make(map[string]struct{}),
+		args:   document.globalArgs(log),
+	}
+	state.platforms = document.platformsOfFroms(log, state.args)
+
+	argImages, err := document.processArgs(log, state, imageNameProcessor)
+	if err != nil {
+		return err
+	}
+	state.argImages = argImages
 
 	curLineNum := 0
 	for _, cmd := range root.Children {
@@ -149,7 +219,11 @@ func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader
 			curLineNum++
 		}
 
-		handled, err := format.processNode(log, cmd, writer, imageNameProcessor)
+		if cmd.Value == "from" {
+			state.stage = stageName(cmd)
+		}
+
+		handled, err := document.processNode(log, cmd, state, writer, imageNameProcessor)
 		if err != nil {
 			return err
 		}
@@ -163,6 +237,10 @@ func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader
 			}
 		}
 		curLineNum = endLine
+
+		if state.stage != "" {
+			state.stages[strings.ToLower(state.stage)] = struct{}{}
+		}
 	}
 
 	lastCommand := root.Children[len(root.Children)-1]
@@ -176,6 +254,42 @@ func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader
 	return result.ErrorOrNil()
 }
 
+// locationOfFrom returns the location of the image of the FROM instruction node
+func (document *dockerfileDocument) locationOfFrom(node *parser.Node) dockfmt.Location {
+	location := dockfmt.Location{
+		Line:        node.StartLine,
+		Instruction: "FROM",
+		Stage:       stageName(node),
+	}
+
+	if w, ok := document.fromWord(node); ok {
+		wordLocation := document.location(w, 0)
+		location.Line = wordLocation.Line
+		location.Column = wordLocation.Column
+	}
+
+	return location
+}
+
+// fromWord returns the word of the image of the FROM instruction node, the first word after the flags
+func (document *dockerfileDocument) fromWord(node *parser.Node) (word, bool) {
+	words := document.words(node)
+	i := 1 + len(flagWords(words))
+	if i >= len(words) || words[i].value != node.Next.Value {
+		return word{}, false
+	}
+	return words[i], true
+}
+
+// stageName returns the name given to the build stage with FROM image AS name
+func stageName(node *parser.Node) string {
+	image := node.Next
+	if image == nil || image.Next == nil || !strings.EqualFold(image.Next.Value, "as") || image.Next.Next == nil {
+		return ""
+	}
+	return image.Next.Next.Value
+}
+
 func endLineOfNode(command *parser.Node) int {
 	v := reflect.ValueOf(*command)
 	y := v.FieldByName("endLine")
@@ -183,11 +297,40 @@ func endLineOfNode(command *parser.Node) int {
 	return endLine
 }
 
-func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser.Node, writer *bufio.Writer, imageNameProcessor dockfmt.ImageNameProcessor) (bool, error) {
+func (document *dockerfileDocument) processNode(log logrus.FieldLogger, node *parser.Node, state *processState, writer *bufio.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) (bool, error) {
 	result := new(multierror.Error)
 
+	var lines []string
+	switch node.Value {
+	case "arg":
+		lines = document.argLines(node, state.args, state.argImages)
+	case "copy", "run":
+		var err error
+		lines, err = document.processFlags(log, node, state, imageNameProcessor)
+		if err != nil {
+			return false, err
+		}
+	}
+	if lines != nil {
+		for _, line := range lines {
+			_, err := writer.WriteString(line)
+			result = multierror.Append(result, err)
+		}
+		return true, result.ErrorOrNil()
+	}
+
 	if node.Value == "from" {
 		from := node.Next.Value
+
+		if strings.Contains(from, "$") {
+			// images that are the value of an ARG are rewritten in the ARG
+			return false, document.processSubstitutedFrom(log, node, state, imageNameProcessor)
+		}
+
+		if state.isStage(from) {
+			log.Infof("Skipping %s, it is a build stage", from)
+			return false, nil
+		}
 		log.Infof("Found image %s", from)
 
 		ref, err := dockref.Parse(from)
@@ -195,34 +338,26 @@ func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser
 			return false, err
 		}
 
-		processed, err := imageNameProcessor(ref)
+		location := document.locationOfFrom(node)
+		location.Platform = state.platforms[node]
+		processed, err := imageNameProcessor(ref, location)
 		if err != nil {
 			return false, err
 		}
 
-		if processed != ref {
-			log.Infof("Pinning '%s' as '%s'", from, processed)
+		formatted := processed.String()
+		if formatted == from {
+			return false, nil
 		}
+		log.Infof("Pinning '%s' as '%s'", from, formatted)
 
-		//writer.WriteString(`FROM `)
-		//writer.WriteString(processed)
-
-		//next := node.Next.Next
-
-		//for next != nil {
-		//	writer.WriteString(` `)
-		//	writer.WriteString(next.Value)
-		//
-		//	next = next.Next
-		//}
-
-		end := endLineOfNode(node)
-		start := node.StartLine
+		w, ok := document.fromWord(node)
+		if !ok {
+			return false, errors.Errorf("Cannot find image %s of FROM in line %d", from, node.StartLine)
+		}
 
-		for i := start; i <= end; i++ {
-			line := format.lines[i-1]
-			formattedImageReference := processed.String()
-			_, err := writer.WriteString(strings.Replace(line, from, formattedImageReference, 1))
+		for _, line := range document.applyEdits(node, replacement(w, 0, len(w.value), formatted)) {
+			_, err := writer.WriteString(line)
 			result = multierror.Append(result, err)
 		}
 		return true, result.ErrorOrNil()
@@ -26,10 +26,25 @@ func TestDockerfileName(t *testing.T) {
 	assert.Equal(t, "Dockerfile", name)
 }
 
+func TestDockerfileMatchesFilename(t *testing.T) {
+	format := newDockerfileFormat()
+	for _, filename := range []string{
+		"Dockerfile", "dockerfile", "Containerfile", "Dockerfile.prod", "Containerfile.arm64",
+		"api.Dockerfile", "web.containerfile", "/path/to/build/Dockerfile", "dir/Dockerfile.dev",
+	} {
+		assert.True(t, format.MatchesFilename(filename), filename)
+	}
+	for _, filename := range []string{
+		"", "-", "Dockerfiles", "MyDockerfile", "docker-compose.yml", "Dockerfile/values.yaml", ".dockerignore",
+	} {
+		assert.False(t, format.MatchesFilename(filename), filename)
+	}
+}
+
 func TestDockerfileFormatEmptyIsInvalid(t *testing.T) {
 	file := ``
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Error(t, valid)
 }
@@ -37,7 +52,7 @@ func TestDockerfileFormatEmptyIsInvalid(t *testing.T) {
 func TestDockerfileFormatMissingFromIsInvalid(t *testing.T) {
 	file := `RUN command`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Error(t, valid)
 }
@@ -45,7 +60,7 @@ func TestDockerfileFormatMissingFromIsInvalid(t *testing.T) {
 func TestDockerfileFormatOtherIsInvalid(t *testing.T) {
 	file := `other stuff`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Error(t, valid)
 }
@@ -53,7 +68,7 @@ func TestDockerfileFormatOtherIsInvalid(t *testing.T) {
 func TestDockerfileFromScratchIsValid(t *testing.T) {
 	file := `FROM scratch`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Nil(t, valid)
 }
@@ -62,7 +77,7 @@ func TestDockerfileFromScratchPlusInvalidIsInvalid(t *testing.T) {
 	file := `FROM scratch
 Invalid thing`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Error(t, valid)
 }
@@ -70,7 +85,7 @@ Invalid thing`
 func TestDockerfileFromNginxIsValid(t *testing.T) {
 	file := `FROM nginx`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Nil(t, valid)
 }
@@ -78,7 +93,7 @@ func TestDockerfileFromNginxIsValid(t *testing.T) {
 func TestDockerfileFromNginxWithTagIsValid(t *testing.T) {
 	file := `FROM nginx:tag`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Nil(t, valid)
 }
@@ -87,7 +102,7 @@ func TestDockerfileMultiFromIsValid(t *testing.T) {
 	file := `FROM nginx:tag
 FROM something:tag`
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Nil(t, valid)
 }
@@ -97,7 +112,7 @@ RUN some \
 	command`
 
 	format := New()
-	valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	assert.Nil(t, valid)
 }
@@ -110,9 +125,9 @@ RUN some \
 FROM something:tag`
 	format := New()
 	calls := 0
-	format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
 
-	err := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		calls++
 		return r, nil
 	})
@@ -124,10 +139,10 @@ FROM something:tag`
 func TestDockerfilePassProcessorErrors(t *testing.T) {
 	file := `FROM valid`
 	format := New()
-	format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	expected := errors.New("expected")
-	err := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		return r, expected
 	})
 
@@ -145,10 +160,10 @@ RUN something \
 
 # And a comment`
 	format := New()
-	format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	calls := 0
-	err := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		calls++
 		return r, nil
 	})
@@ -157,12 +172,555 @@ RUN something \
 	assert.Equal(t, 2, calls)
 }
 
+func TestDockerfileReportsLocations(t *testing.T) {
+	file := `FROM nginx:tag AS build
+RUN some \
+	command
+
+FROM --platform=linux/amd64 \
+	nginx
+from   nginx:tag as final`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	locations := make([]dockfmt.Location, 0)
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 1, Column: 6, Stage: "build", Instruction: "FROM"},
+		{Line: 6, Column: 2, Instruction: "FROM", Platform: "linux/amd64"},
+		{Line: 7, Column: 8, Stage: "final", Instruction: "FROM"},
+	}, locations)
+}
+
+func TestDockerfileCallsProcessorForFlagImages(t *testing.T) {
+	file := `FROM golang:1.21 AS Build
+COPY --from=alpine:3.18 /etc/ssl /etc/ssl
+RUN --mount=type=bind,from=golang:1.21,source=/go,target=/go \
+	--mount=type=cache,target=/root/.cache go build
+
+FROM scratch
+COPY --from=build /app /app
+COPY --from=0 /go /go
+COPY --from=$BASE /x /x
+RUN --mount=from=busybox,target=/bin echo
+COPY --chown=1:1 --from=nginx /etc/nginx /etc/nginx`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	images := make([]string, 0)
+	locations := make([]dockfmt.Location, 0)
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"golang:1.21", "alpine:3.18", "golang:1.21", "scratch", "busybox", "nginx"}, images)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 1, Column: 6, Stage: "Build", Instruction: "FROM"},
+		{Line: 2, Column: 13, Stage: "Build", Instruction: "COPY"},
+		{Line: 3, Column: 28, Stage: "Build", Instruction: "RUN"},
+		{Line: 6, Column: 6, Instruction: "FROM"},
+		{Line: 10, Column: 18, Instruction: "RUN"},
+		{Line: 11, Column: 25, Instruction: "COPY"},
+	}, locations)
+}
+
+func TestDockerfileRewritesFlagImages(t *testing.T) {
+	file := `FROM golang:1.21 AS build
+COPY --from=alpine /etc/ssl /etc/ssl
+RUN --mount=type=bind,from=golang,source=/go \
+	--mount=from=golang,target=/x go build
+COPY --from=build /app /app
+`
+	expected := `FROM golang:pinned AS build
+COPY --from=alpine:pinned /etc/ssl /etc/ssl
+RUN --mount=type=bind,from=golang:pinned,source=/go \
+	--mount=from=golang:pinned,target=/x go build
+COPY --from=build /app /app
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	buffer := bytes.NewBuffer(nil)
+	err := document.Process(log, buffer, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestDockerfileFailsWhenFlagImageCannotBeFound(t *testing.T) {
+	file := `FROM scratch
+COPY --from="alpine" /etc/ssl /etc/ssl
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	images := make([]string, 0)
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Equal(t, []string{"scratch", "alpine"}, images)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Cannot find image alpine of COPY in line 2")
+}
+
+func TestDockerfileRewritesOnlyTheImageToken(t *testing.T) {
+	file := `FROM --platform=linux/amd64 amd64 AS amd64
+FROM nginx AS nginxbuild
+RUN echo nginx
+# nginx in a comment
+FROM \
+  # nginx in a continued comment
+  nginx \
+  AS nginx
+COPY --from=nginx --chown=nginx /a /a
+COPY --chown=alpine --from=alpine /etc/alpine /etc/alpine
+`
+	expected := `FROM --platform=linux/amd64 amd64:pinned AS amd64
+FROM nginx:pinned AS nginxbuild
+RUN echo nginx
+# nginx in a comment
+FROM \
+  # nginx in a continued comment
+  nginx:pinned \
+  AS nginx
+COPY --from=nginx --chown=nginx /a /a
+COPY --chown=alpine --from=alpine:pinned /etc/alpine /etc/alpine
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	locations := make([]dockfmt.Location, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 1, Column: 29, Stage: "amd64", Instruction: "FROM", Platform: "linux/amd64"},
+		{Line: 2, Column: 6, Stage: "nginxbuild", Instruction: "FROM"},
+		{Line: 7, Column: 3, Stage: "nginx", Instruction: "FROM"},
+		{Line: 10, Column: 28, Stage: "nginx", Instruction: "COPY"},
+	}, locations)
+}
+
+func TestDockerfileRewritesImagesSplitByLineContinuations(t *testing.T) {
+	file := `ARG BASE=alpi\
+ne
+FROM ngi\
+nx:1.15 AS build
+FROM ${BASE}
+`
+	expected := `ARG BASE=\
+alpine:pinned
+FROM \
+nginx:pinned AS build
+FROM ${BASE}
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	images := make([]string, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine", "nginx:1.15"}, images)
+	assert.Equal(t, expected, out)
+}
+
+func TestDockerfileEscapeDirective(t *testing.T) {
+	file := "# syntax=docker/dockerfile:1\r\n" +
+		"# escape=`\r\n" +
+		"FROM mcr.microsoft.com/windows/servercore:ltsc2019 `\r\n" +
+		"  AS build\r\n" +
+		"RUN dir C:\\\r\n" +
+		"COPY --from=nginx C:\\nginx C:\\nginx\r\n"
+	expected := "# syntax=docker/dockerfile:pinned\r\n" +
+		"# escape=`\r\n" +
+		"FROM mcr.microsoft.com/windows/servercore:pinned `\r\n" +
+		"  AS build\r\n" +
+		"RUN dir C:\\\r\n" +
+		"COPY --from=nginx:pinned C:\\nginx C:\\nginx\r\n"
+	format := New()
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	locations := make([]dockfmt.Location, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 1, Column: 10, Instruction: "# syntax"},
+		{Line: 3, Column: 6, Stage: "build", Instruction: "FROM"},
+		{Line: 6, Column: 13, Stage: "build", Instruction: "COPY"},
+	}, locations)
+}
+
+func TestDockerfileHeredocs(t *testing.T) {
+	file := `# syntax=docker/dockerfile:1.4
+FROM alpine
+RUN <<EOF
+apk add curl
+FROM nginx
+EOF
+COPY --from=busybox <<-EOT /etc/motd
+	COPY --from=nginx /a /a
+	EOT
+RUN <<A cat >/a && <<"B" cat >/b
+a
+A
+b
+B
+FROM debian
+`
+	format := New()
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	images := make([]string, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"docker/dockerfile:1.4", "alpine", "busybox", "debian"}, images)
+	assert.Equal(t, file, out)
+}
+
+func TestDockerfileSyntaxIsOnlyADirectiveAtTheTop(t *testing.T) {
+	file := `# syntax is a comment here
+FROM alpine
+# syntax=docker/dockerfile:1`
+	format := New()
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	images := make([]string, 0)
+	_, err = processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine"}, images)
+}
+
+func processDockerfile(t *testing.T, document dockfmt.Document, imageNameProcessor dockfmt.LocatedImageNameProcessor) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	err := document.Process(log, buffer, imageNameProcessor)
+	return buffer.String(), err
+}
+
+func TestDockerfileReportsArgImagesAtTheArg(t *testing.T) {
+	file := `# base image
+ARG BASE=node:20
+ARG OTHER
+FROM ${BASE} AS build
+FROM $BASE`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	images := make([]string, 0)
+	locations := make([]dockfmt.Location, 0)
+	_, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node:20"}, images)
+	assert.Equal(t, []dockfmt.Location{{Line: 2, Column: 10, Stage: "build", Instruction: "ARG"}}, locations)
+}
+
+func TestDockerfilePinRewritesArgDefaults(t *testing.T) {
+	file := `ARG BASE="node:20" RUNTIME=alpine \
+	TOOLS=busybox
+FROM ${BASE} AS build
+RUN build
+FROM ${RUNTIME}
+FROM ${BASE}
+`
+	expected := `ARG BASE="node:pinned" RUNTIME=alpine:pinned \
+	TOOLS=busybox
+FROM ${BASE} AS build
+RUN build
+FROM ${RUNTIME}
+FROM ${BASE}
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	calls := 0
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		calls++
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, expected, out)
+}
+
+func TestDockerfilePinRewritesArgDefaultsUsedAsTag(t *testing.T) {
+	file := `ARG TAG=3.18
+ARG VERSION=20
+FROM alpine:${TAG}
+FROM node:$VERSION AS build
+FROM alpine:${TAG}
+`
+	expected := `ARG TAG=3.18@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf
+ARG VERSION=20-pinned
+FROM alpine:${TAG}
+FROM node:$VERSION AS build
+FROM alpine:${TAG}
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	images := make([]string, 0)
+	locations := make([]dockfmt.Location, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		locations = append(locations, location)
+		if r.Original() == "node:20" {
+			return r.WithTag("20-pinned"), nil
+		}
+		return r.WithDigest("sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf").
+			WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag | dockref.FormatHasDigest)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine:3.18", "node:20"}, images)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 1, Column: 9, Instruction: "ARG"},
+		{Line: 2, Column: 13, Stage: "build", Instruction: "ARG"},
+	}, locations)
+	assert.Equal(t, expected, out)
+}
+
+func TestDockerfileSubstitutedImagesThatCannotBeRewritten(t *testing.T) {
+	pin := func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	}
+
+	cases := map[string]string{
+		"image with ARG": `ARG REGISTRY=example.com
+FROM ${REGISTRY}/node:20
+`,
+		"tag of different images": `ARG VERSION=20
+FROM node:${VERSION}
+FROM nginx:${VERSION}
+`,
+		"tag used in a stage": `ARG TAG=3.18
+FROM alpine:${TAG}
+ARG TAG
+RUN echo $TAG
+`,
+		"tag used in another image": `ARG TAG=3.18
+FROM alpine:${TAG}
+FROM example.com/alpine:${TAG}
+`,
+	}
+
+	for name, file := range cases {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+			images := make([]string, 0)
+			out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+				images = append(images, r.Original())
+				return r, nil
+			})
+			assert.Nil(t, err)
+			assert.NotEmpty(t, images)
+			assert.Equal(t, file, out)
+
+			_, err = processDockerfile(t, document, pin)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "Cannot rewrite image")
+		})
+	}
+}
+
+func TestDockerfileUnresolvableSubstitutionsAreSkipped(t *testing.T) {
+	file := `ARG REGISTRY
+FROM ${REGISTRY}/node
+FROM ${UNKNOWN}
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	calls := 0
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		calls++
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, file, out)
+}
+
+func TestDockerfileBuildArgsReplaceArgDefaults(t *testing.T) {
+	file := `ARG BASE=node:20
+ARG REGISTRY
+FROM ${BASE}
+FROM ${REGISTRY}/node
+`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+	document = document.(dockfmt.BuildArgsDocument).WithBuildArgs(map[string]string{
+		"BASE":     "node:18",
+		"REGISTRY": "example.com",
+	})
+
+	images := make([]string, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node:18", "example.com/node"}, images)
+	assert.Equal(t, file, out)
+
+	// the values of build args are not in the file
+	_, err = processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+	assert.Error(t, err)
+}
+
+func TestDockerfileSkipsStageReferences(t *testing.T) {
+	file := `ARG STAGE=builder
+FROM golang:1.21 AS Builder
+FROM builder AS test
+COPY --from=BUILDER /app /app
+RUN --mount=from=test,target=/x true
+FROM ${STAGE}
+FROM alpine
+COPY --from=builder /app /app`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	images := make([]string, 0)
+	locations := make([]dockfmt.Location, 0)
+	out, err := processDockerfile(t, document, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		locations = append(locations, location)
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"golang:1.21", "alpine"}, images)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 2, Column: 6, Stage: "Builder", Instruction: "FROM"},
+		{Line: 7, Column: 6, Instruction: "FROM"},
+	}, locations)
+	assert.Contains(t, out, "FROM builder AS test\n")
+	assert.Contains(t, out, "FROM alpine:pinned\n")
+}
+
+func TestDockerfileReportsPlatforms(t *testing.T) {
+	file := `ARG ARCH=arm64
+ARG BASE=debian:12
+FROM --platform=linux/arm64 debian:12
+FROM --platform=linux/$ARCH debian:11
+FROM --platform=$TARGETPLATFORM alpine
+FROM --platform=$BUILDPLATFORM golang
+FROM --platform=linux/amd64 $BASE`
+	format := New()
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
+	document = document.(dockfmt.BuildArgsDocument).WithBuildArgs(map[string]string{"TARGETPLATFORM": "linux/arm/v7"})
+
+	platforms := make([]string, 0)
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		platforms = append(platforms, r.Original()+" "+location.Platform)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"debian:12 linux/amd64",
+		"debian:12 linux/arm64",
+		"debian:11 linux/arm64",
+		"alpine linux/arm/v7",
+		"golang ",
+	}, platforms)
+}
+
+func TestDockerfileAutomaticPlatformsAreNotResolvedWithoutBuildArgs(t *testing.T) {
+	file := `ARG BASE=debian:12
+FROM --platform=$BUILDPLATFORM $BASE AS build
+FROM --platform=$TARGETOS/$TARGETARCH alpine
+FROM --platform=$UNKNOWN $BASE`
+	format := New()
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	output := bytes.NewBuffer(nil)
+	logger := logrus.New()
+	logger.SetOutput(output)
+
+	platforms := make([]string, 0)
+	err = document.Process(logger, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		platforms = append(platforms, r.Original()+" "+location.Platform)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"debian:12 ", "alpine "}, platforms)
+	assert.Equal(t, 1, strings.Count(output.String(), "level=warning"))
+	assert.Contains(t, output.String(), "Ignoring platform $UNKNOWN in line 4")
+}
+
+func TestDockerfileDocumentsAreIndependent(t *testing.T) {
+	format := New()
+	first, _ := format.ValidateInput(log, strings.NewReader(`FROM first`), "first")
+	second, _ := format.ValidateInput(log, strings.NewReader(`FROM second`), "second")
+
+	images := make([]string, 0)
+	processor := func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	}
+	errFirst := first.Process(log, bytes.NewBuffer(nil), processor)
+	errSecond := second.Process(log, bytes.NewBuffer(nil), processor)
+
+	assert.Nil(t, errFirst)
+	assert.Nil(t, errSecond)
+	assert.Equal(t, []string{"first", "second"}, images)
+}
+
 func TestDockerfileInvalidFromReported(t *testing.T) {
 	file := `FROM nginx:a:b`
 	format := New()
-	format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, _ := format.ValidateInput(log, strings.NewReader(file), "anything")
 
-	processErr := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+	processErr := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		return r, nil
 	})
 
@@ -178,8 +736,9 @@ func TestParserErrorsAreReported(t *testing.T) {
 		return nil, expected
 	}
 
-	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
 
+	assert.Nil(t, document)
 	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
 }
 
@@ -187,9 +746,9 @@ func TestParserSha256(t *testing.T) {
 	file := `FROM nginx@sha256:db5acc22920799fe387a903437eb89387607e5b3f63cf0f4472ac182d7bad644`
 	format := New()
 
-	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
 
-	processErr := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+	processErr := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		return r, nil
 	})
 
@@ -206,10 +765,10 @@ func TestProcessLogsReplacingReferences(t *testing.T) {
 	file := `FROM nginx`
 	format := New()
 
-	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
 
 	expected := dockref.MustParse("pinned")
-	processErr := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+	processErr := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		return expected, nil
 	})
 
@@ -236,9 +795,9 @@ func TestDockerfileFormat_ReportsFlushError(t *testing.T) {
 	file := `FROM nginx`
 	format := New()
 
-	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
 
-	processErr := format.Process(log, strings.NewReader(file), failingWriter{}, func(r dockref.Reference) (dockref.Reference, error) {
+	processErr := document.Process(log, failingWriter{}, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
 		return dockref.MustParse("pinned"), nil
 	})
 
@@ -0,0 +1,128 @@
+package dockerfile
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// flagImage is an image used in a flag, e.g. COPY --from=alpine or RUN --mount=type=bind,from=golang
+type flagImage struct {
+	prefix string
+	image  string
+}
+
+// flagImages returns the images of the --from flag of COPY and the from option of RUN --mount.
+// References to build stages, stage indices and variables are skipped.
+func flagImages(log logrus.FieldLogger, node *parser.Node, state *processState) []flagImage {
+	images := make([]flagImage, 0)
+	for _, flag := range node.Flags {
+		switch {
+		case node.Value == "copy" && strings.HasPrefix(flag, "--from="):
+			images = appendFlagImage(log, images, "--from=", strings.TrimPrefix(flag, "--from="), state)
+		case node.Value == "run" && strings.HasPrefix(flag, "--mount="):
+			for _, option := range strings.Split(strings.TrimPrefix(flag, "--mount="), ",") {
+				if strings.HasPrefix(option, "from=") {
+					images = appendFlagImage(log, images, "from=", strings.TrimPrefix(option, "from="), state)
+				}
+			}
+		}
+	}
+	return images
+}
+
+func appendFlagImage(log logrus.FieldLogger, images []flagImage, prefix string, image string, state *processState) []flagImage {
+	if image == "" || state.isStage(image) {
+		return images
+	}
+	if _, err := strconv.Atoi(image); err == nil {
+		return images
+	}
+	if strings.Contains(image, "$") {
+		log.Warnf("Skipping image %s, variable substitution is not supported", image)
+		return images
+	}
+	return append(images, flagImage{prefix: prefix, image: image})
+}
+
+// indexOfFlagValue returns the index of value in flag when it follows prefix and ends the flag or option
+func indexOfFlagValue(flag string, prefix string, value string, offset int) int {
+	for offset <= len(flag) {
+		idx := strings.Index(flag[offset:], prefix+value)
+		if idx < 0 {
+			return -1
+		}
+		start := offset + idx
+		end := start + len(prefix) + len(value)
+		if (start == 0 || isFlagSeparator(flag[start-1])) && (end == len(flag) || isFlagSeparator(flag[end])) {
+			return start + len(prefix)
+		}
+		offset = start + 1
+	}
+	return -1
+}
+
+// isFlagSeparator returns true for characters that can delimit the value of a flag or an option of --mount
+func isFlagSeparator(c byte) bool {
+	return c == ',' || c == '=' || c == '"' || c == '\''
+}
+
+// processFlags calls imageNameProcessor for the images in the flags of node and writes the lines with the processed images
+func (document *dockerfileDocument) processFlags(log logrus.FieldLogger, node *parser.Node, state *processState, imageNameProcessor dockfmt.LocatedImageNameProcessor) ([]string, error) {
+	images := flagImages(log, node, state)
+	if len(images) == 0 {
+		return nil, nil
+	}
+
+	flags := flagWords(document.words(node))
+	edits := make([]edit, 0)
+
+	// flags appear in order, the search continues after the previous image
+	flag, offset := 0, 0
+	for _, image := range images {
+		log.Infof("Found image %s", image.image)
+		ref, err := dockref.Parse(image.image)
+		if err != nil {
+			return nil, err
+		}
+
+		location := dockfmt.Location{
+			Line:        node.StartLine,
+			Instruction: strings.ToUpper(node.Value),
+			Stage:       state.stage,
+		}
+		index := -1
+		for ; flag < len(flags); flag, offset = flag+1, 0 {
+			index = indexOfFlagValue(flags[flag].value, image.prefix, image.image, offset)
+			if index >= 0 {
+				flagLocation := document.location(flags[flag], index)
+				location.Line = flagLocation.Line
+				location.Column = flagLocation.Column
+				offset = index + len(image.image)
+				break
+			}
+		}
+
+		processed, err := imageNameProcessor(ref, location)
+		if err != nil {
+			return nil, err
+		}
+
+		formatted := processed.String()
+		if formatted == image.image {
+			continue
+		}
+		if index < 0 {
+			return nil, errors.Errorf("Cannot find image %s of %s in line %d", image.image, location.Instruction, node.StartLine)
+		}
+		log.Infof("Pinning '%s' as '%s'", image.image, formatted)
+		edits = append(edits, replacement(flags[flag], index, len(image.image), formatted)...)
+	}
+
+	return document.applyEdits(node, edits), nil
+}
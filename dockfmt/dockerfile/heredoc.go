@@ -0,0 +1,75 @@
+package dockerfile
+
+import (
+	"regexp"
+	"strings"
+)
+
+// heredocInstructions are the instructions that support heredocs, e.g. RUN <<EOF
+var heredocInstructions = map[string]struct{}{
+	"run":  {},
+	"copy": {},
+	"add":  {},
+}
+
+var heredocPattern = regexp.MustCompile(`<<(-?)(?:"([A-Za-z_][A-Za-z0-9_]*)"|'([A-Za-z_][A-Za-z0-9_]*)'|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// heredoc is the start of a heredoc, its body ends with a line that only contains the delimiter
+type heredoc struct {
+	delimiter string
+	// stripTabs is true for <<-, leading tabs of the body and the delimiter line are ignored
+	stripTabs bool
+}
+
+func (h heredoc) isEnd(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	if h.stripTabs {
+		line = strings.TrimLeft(line, "\t")
+	}
+	return line == h.delimiter
+}
+
+// heredocs returns the heredocs started in line
+func heredocs(line string) []heredoc {
+	docs := make([]heredoc, 0)
+	for _, match := range heredocPattern.FindAllStringSubmatch(line, -1) {
+		docs = append(docs, heredoc{
+			delimiter: match[2] + match[3] + match[4],
+			stripTabs: match[1] == "-",
+		})
+	}
+	return docs
+}
+
+// heredocBodies returns the 1-based numbers of the lines in the bodies of heredocs, including the delimiter lines.
+// The bodies start after the instruction that declares them and follow each other in the order of the declarations.
+func heredocBodies(lines []string, escape byte) []int {
+	continuation := regexp.MustCompile(regexp.QuoteMeta(string(escape)) + `[ \t]*$`)
+
+	bodies := make([]int, 0)
+	pending := make([]heredoc, 0)
+	instruction := ""
+	continued := false
+	for i, line := range lines {
+		if len(pending) > 0 && !continued {
+			bodies = append(bodies, i+1)
+			if pending[0].isEnd(line) {
+				pending = pending[1:]
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !continued {
+			instruction = strings.ToLower(strings.Fields(trimmed)[0])
+		}
+		if _, ok := heredocInstructions[instruction]; ok {
+			pending = append(pending, heredocs(line)...)
+		}
+		continued = continuation.MatchString(strings.TrimRight(line, "\r\n"))
+	}
+	return bodies
+}
@@ -1 +1,141 @@
 package dockerfile
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// position is the 1-based line and the byte index in that line of a character of an instruction
+type position struct {
+	line  int
+	index int
+}
+
+// word is a whitespace delimited word of an instruction with the positions of its characters in the original lines.
+// Words can span several lines when they are split by a line continuation.
+type word struct {
+	value     string
+	positions []position
+}
+
+// location returns the location of the character at offset of the word
+func (document *dockerfileDocument) location(w word, offset int) dockfmt.Location {
+	p := w.positions[offset]
+	return dockfmt.Location{
+		Line:   p.line,
+		Column: utf8.RuneCountInString(document.lines[p.line-1][:p.index]) + 1,
+	}
+}
+
+// words splits the instruction node into words like the parser does. Line continuations, comments and empty lines
+// inside the instruction are removed, quotes are kept in the words but whitespace inside quotes does not split words.
+func (document *dockerfileDocument) words(node *parser.Node) []word {
+	continuation := regexp.MustCompile(regexp.QuoteMeta(string(document.result.EscapeToken)) + `[ \t]*$`)
+
+	// the logical line of the instruction and the positions of its characters
+	logical := make([]byte, 0)
+	positions := make([]position, 0)
+	for i := node.StartLine; i <= endLineOfNode(node); i++ {
+		line := strings.TrimRight(document.lines[i-1], "\r\n")
+		start := 0
+		trimmed := strings.TrimLeft(line, " \t")
+		if i == node.StartLine {
+			start = len(line) - len(trimmed)
+		} else if strings.HasPrefix(trimmed, "#") || trimmed == "" {
+			continue
+		}
+		end := len(line)
+		if loc := continuation.FindStringIndex(line); loc != nil && loc[0] >= start {
+			end = loc[0]
+		}
+		for j := start; j < end; j++ {
+			logical = append(logical, line[j])
+			positions = append(positions, position{line: i, index: j})
+		}
+	}
+
+	words := make([]word, 0)
+	var current *word
+	quote := byte(0)
+	for i, c := range logical {
+		if quote == 0 && (c == ' ' || c == '\t') {
+			current = nil
+			continue
+		}
+		if current == nil {
+			words = append(words, word{})
+			current = &words[len(words)-1]
+		}
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == c:
+			quote = 0
+		}
+		current.value += string(c)
+		current.positions = append(current.positions, positions[i])
+	}
+	return words
+}
+
+// flagWords returns the words of the flags of the instruction, the words starting with -- after the instruction
+func flagWords(words []word) []word {
+	for i := 1; i < len(words); i++ {
+		if !strings.HasPrefix(words[i].value, "--") {
+			return words[1:i]
+		}
+	}
+	if len(words) == 0 {
+		return words
+	}
+	return words[1:]
+}
+
+// edit replaces length bytes at index of a line with text
+type edit struct {
+	line   int
+	index  int
+	length int
+	text   string
+}
+
+// replacement returns the edits to replace length characters at offset of the word with text.
+// The text replaces the characters on the last line, so no empty continuation line is left, characters on the
+// lines before are removed.
+func replacement(w word, offset int, length int, text string) []edit {
+	edits := make([]edit, 0)
+	for _, p := range w.positions[offset : offset+length] {
+		last := len(edits) - 1
+		if last >= 0 && edits[last].line == p.line && edits[last].index+edits[last].length == p.index {
+			edits[last].length++
+			continue
+		}
+		edits = append(edits, edit{line: p.line, index: p.index, length: 1})
+	}
+	edits[len(edits)-1].text = text
+	return edits
+}
+
+// applyEdits returns the lines of the instruction node with the edits applied
+func (document *dockerfileDocument) applyEdits(node *parser.Node, edits []edit) []string {
+	start := node.StartLine
+	lines := append([]string(nil), document.lines[start-1:endLineOfNode(node)]...)
+
+	// right to left, so the indices of the remaining edits stay valid
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line > edits[j].line
+		}
+		return edits[i].index > edits[j].index
+	})
+	for _, e := range edits {
+		l := lines[e.line-start]
+		lines[e.line-start] = l[:e.index] + e.text + l[e.index+e.length:]
+	}
+	return lines
+}
@@ -0,0 +1,75 @@
+package dockerfile
+
+import (
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/sirupsen/logrus"
+)
+
+// automaticPlatformArgs are predefined by the builder, they can be set with build args to pin for a platform
+var automaticPlatformArgs = []string{
+	"BUILDPLATFORM", "BUILDOS", "BUILDARCH", "BUILDVARIANT",
+	"TARGETPLATFORM", "TARGETOS", "TARGETARCH", "TARGETVARIANT",
+}
+
+// automaticPlatformMarker is the value of automatic platform ARGs that are not set, it cannot be part of a platform
+const automaticPlatformMarker = "\x00"
+
+// platformsOfFroms returns the platform of each FROM instruction, see platformOfFrom.
+// The platforms are computed once, so unresolvable platforms are only reported once.
+func (document *dockerfileDocument) platformsOfFroms(log logrus.FieldLogger, args map[string]*globalArg) map[*parser.Node]string {
+	platforms := make(map[*parser.Node]string)
+	for _, node := range document.result.AST.Children {
+		if node.Value == "from" {
+			platforms[node] = document.platformOfFrom(log, node, args)
+		}
+	}
+	return platforms
+}
+
+// platformOfFrom returns the --platform of the FROM instruction node with the global ARGs substituted.
+// The automatic platform ARGs are only used when they are set with build args, otherwise the platform depends on the
+// builder and is left empty, so the manifest list is used instead of the manifest of the platform dockmoor runs on.
+// The platform is empty when FROM has no --platform or the ARGs cannot be resolved.
+func (document *dockerfileDocument) platformOfFrom(log logrus.FieldLogger, node *parser.Node, args map[string]*globalArg) string {
+	for _, flag := range node.Flags {
+		if !strings.HasPrefix(flag, "--platform=") {
+			continue
+		}
+		platform := strings.TrimPrefix(flag, "--platform=")
+		if !strings.Contains(platform, "$") {
+			return platform
+		}
+
+		values := argValues(args)
+		for _, name := range automaticPlatformArgs {
+			if _, ok := values[name]; ok {
+				continue
+			}
+			if value, ok := document.buildArgs[name]; ok {
+				values[name] = value
+			} else {
+				values[name] = automaticPlatformMarker
+			}
+		}
+
+		lex := shell.NewLex(document.result.EscapeToken)
+		expanded, err := lex.ProcessWordWithMap(platform, values)
+		if err == nil && strings.Contains(expanded, automaticPlatformMarker) {
+			log.Debugf("Using the manifest list for platform %s in line %d, the platform depends on the builder", platform, node.StartLine)
+			return ""
+		}
+		if err == nil {
+			_, err = dockref.ParsePlatform(expanded)
+		}
+		if err != nil {
+			log.Warnf("Ignoring platform %s in line %d, the ARGs cannot be resolved", platform, node.StartLine)
+			return ""
+		}
+		return expanded
+	}
+	return ""
+}
@@ -2,35 +2,111 @@ package dockfmt
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/sirupsen/logrus"
 )
 
+// Format is a stateless file format, it is safe for concurrent use
 type Format interface {
 	Name() string
-	ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error
-	Process(log logrus.FieldLogger, reader io.Reader, writer io.Writer, imageNameProcessor ImageNameProcessor) error
+	// ValidateInput parses the content of reader, the returned Document is nil when the content is invalid
+	ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (Document, error)
 }
+
+// FilenameFormat is a Format with a naming convention for its files, e.g. Dockerfile or api.Dockerfile
+type FilenameFormat interface {
+	Format
+	// MatchesFilename returns true when the name of the file follows the naming convention of the format
+	MatchesFilename(filename string) bool
+}
+
+// Document is the parsed content of a single input, created by Format.ValidateInput
+type Document interface {
+	// Process calls imageNameProcessor for each image reference and writes the content with the returned
+	// references to writer
+	Process(log logrus.FieldLogger, writer io.Writer, imageNameProcessor LocatedImageNameProcessor) error
+}
+
+// BuildArgsDocument is a Document whose image references depend on build arguments, e.g. ARG in Dockerfiles
+type BuildArgsDocument interface {
+	Document
+	// WithBuildArgs returns a Document that uses the values of buildArgs instead of the defaults in the input
+	WithBuildArgs(buildArgs map[string]string) Document
+}
+
 type ImageNameProcessor func(r dockref.Reference) (dockref.Reference, error)
 
+// LocatedImageNameProcessor is an ImageNameProcessor that also receives the location of the image reference
+type LocatedImageNameProcessor func(r dockref.Reference, location Location) (dockref.Reference, error)
+
+// Location is the position of an image reference in the input
+type Location struct {
+	// File is the name of the file defining the image reference, the input file unless the reference is defined
+	// in another file, e.g. a compose service extended from another file. Empty when unknown.
+	File string
+	// Line is the 1-based line of the image reference, 0 when unknown
+	Line int
+	// Column is the 1-based column (in characters) of the image reference, 0 when unknown
+	Column int
+	// Stage is the name of the build stage, e.g. in Dockerfiles, empty when the stage has no name
+	Stage string
+	// Instruction is the instruction or key containing the image reference, e.g. FROM or services.app.image
+	Instruction string
+	// Platform is the platform the image is used for, e.g. linux/arm64 of FROM --platform=linux/arm64, empty when unknown
+	Platform string
+}
+
+// String formats the location as file:line:column, omitting unknown parts
+func (location Location) String() string {
+	s := location.File
+	if location.Line > 0 {
+		s += fmt.Sprintf(":%d", location.Line)
+		if location.Column > 0 {
+			s += fmt.Sprintf(":%d", location.Column)
+		}
+	}
+	return s
+}
+
 type FormatProcessor interface {
 	Process(imageNameProcessor ImageNameProcessor) error
+	ProcessLocated(imageNameProcessor LocatedImageNameProcessor) error
 	WithWriter(writer io.Writer) FormatProcessor
+	WithFilename(filename string) FormatProcessor
+	WithBuildArgs(buildArgs map[string]string) FormatProcessor
+	Format() Format
 }
 
 var _ FormatProcessor = (*formatProcessor)(nil)
 
 type formatProcessor struct {
-	format Format
-	log    logrus.FieldLogger
-	reader io.Reader
-	writer io.Writer
+	format   Format
+	document Document
+	log      logrus.FieldLogger
+	writer   io.Writer
+	filename string
 }
 
 func (fp *formatProcessor) Process(imageNameProcessor ImageNameProcessor) error {
-	return fp.format.Process(fp.log, fp.reader, fp.writer, imageNameProcessor)
+	return fp.ProcessLocated(func(r dockref.Reference, location Location) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (fp *formatProcessor) ProcessLocated(imageNameProcessor LocatedImageNameProcessor) error {
+	return fp.document.Process(fp.log, fp.writer, func(r dockref.Reference, location Location) (dockref.Reference, error) {
+		if location.File == "" {
+			location.File = fp.filename
+		}
+		return imageNameProcessor(r, location)
+	})
+}
+
+func (fp *formatProcessor) Format() Format {
+	return fp.format
 }
 
 func (fp *formatProcessor) WithWriter(writer io.Writer) FormatProcessor {
@@ -38,14 +114,27 @@ func (fp *formatProcessor) WithWriter(writer io.Writer) FormatProcessor {
 	return fp
 }
 
+func (fp *formatProcessor) WithFilename(filename string) FormatProcessor {
+	fp.filename = filename
+	return fp
+}
+
+// WithBuildArgs sets the build arguments of documents that support them, other documents are not affected
+func (fp *formatProcessor) WithBuildArgs(buildArgs map[string]string) FormatProcessor {
+	if document, ok := fp.document.(BuildArgsDocument); ok {
+		fp.document = document.WithBuildArgs(buildArgs)
+	}
+	return fp
+}
+
 func FormatProcessorNew(format Format,
 	log logrus.FieldLogger,
-	reader io.Reader) FormatProcessor {
+	document Document) FormatProcessor {
 	return &formatProcessor{
-		format: format,
-		log:    log,
-		reader: reader,
-		writer: bytes.NewBuffer(nil),
+		format:   format,
+		document: document,
+		log:      log,
+		writer:   bytes.NewBuffer(nil),
 	}
 }
 
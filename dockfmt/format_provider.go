@@ -1,9 +1,13 @@
 package dockfmt
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -41,7 +45,10 @@ type AmbiguousFormatError struct {
 	Formats []Format
 }
 
-func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reader io.Reader, filename string) (Format, error) {
+// IdentifyFormat reads reader once and validates the full content with every format.
+// The Document parsed by the only matching format is returned for processing.
+// When several formats match, the format whose naming convention matches filename is preferred.
+func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reader io.Reader, filename string) (Format, Document, error) {
 	formats := formatProvider.Formats()
 
 	log = log.WithFields(logrus.Fields{
@@ -49,10 +56,17 @@ func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reade
 		"knownFormats": formats,
 	})
 
-	var format Format
+	// every format has to see the full input, the input can only be read once e.g. for stdin
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var matching []Format
+	var documents []Document
 	var formatErrors error
 	for _, p := range formats {
-		validationErr := p.ValidateInput(log, reader, filename)
+		doc, validationErr := p.ValidateInput(log, bytes.NewReader(content), filename)
 		if validationErr != nil {
 			formatErrors = multierror.Append(formatErrors, validationErr)
 			log.WithFields(logrus.Fields{
@@ -60,22 +74,78 @@ func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reade
 				"error":  validationErr,
 			}).Debug("Tried incompatible format")
 		} else {
-			if format != nil {
-				return nil, AmbiguousFormatError{
-					Formats: []Format{format, p},
+			matching = append(matching, p)
+			documents = append(documents, doc)
+		}
+	}
+
+	if len(matching) == 0 {
+		log.Info("Unknown Format")
+		return nil, nil, UnknownFormatError{
+			formatErrors,
+		}
+	}
+
+	if len(matching) > 1 {
+		preferred := -1
+		for i, format := range matching {
+			if matchesFilename(format, filename) {
+				if preferred >= 0 {
+					preferred = -1
+					break
 				}
+				preferred = i
+			}
+		}
+		if preferred < 0 {
+			names := make([]string, 0, len(matching))
+			for _, format := range matching {
+				names = append(names, format.Name())
 			}
+			return nil, nil, AmbiguousFormatError{
+				error:   errors.Errorf("Ambiguous format, the input is valid for %s", strings.Join(names, ", ")),
+				Formats: matching,
+			}
+		}
+		log.WithField("format", matching[preferred].Name()).Debug("Preferring format by filename")
+		return matching[preferred], documents[preferred], formatErrors
+	}
+
+	return matching[0], documents[0], formatErrors
+}
+
+// matchesFilename returns true when format is a FilenameFormat and filename follows its naming convention
+func matchesFilename(format Format, filename string) bool {
+	filenameFormat, ok := format.(FilenameFormat)
+	return ok && filename != "" && filenameFormat.MatchesFilename(filename)
+}
 
-			format = p
+// FormatByName returns the format with name, names are case insensitive
+func FormatByName(formatProvider FormatProvider, name string) (Format, error) {
+	names := make([]string, 0)
+	for _, format := range formatProvider.Formats() {
+		if strings.EqualFold(format.Name(), name) {
+			return format, nil
 		}
+		names = append(names, format.Name())
 	}
+	return nil, errors.Errorf("Unknown format %s, known formats are %s", name, strings.Join(names, ", "))
+}
 
-	if format == nil {
-		log.Info("Unknown Format")
-		return nil, UnknownFormatError{
-			formatErrors,
+// ValidateNamedFormat reads reader and validates the content with the format name, without identifying the format.
+// Content that is invalid for the format is reported as UnknownFormatError.
+func ValidateNamedFormat(log logrus.FieldLogger, formatProvider FormatProvider, name string, reader io.Reader, filename string) (Format, Document, error) {
+	format, err := FormatByName(formatProvider, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	document, err := format.ValidateInput(log.WithField("filename", filename), reader, filename)
+	if err != nil {
+		return nil, nil, UnknownFormatError{
+			err,
 		}
 	}
 
-	return format, formatErrors
+	return format, document, nil
 }
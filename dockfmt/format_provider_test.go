@@ -2,6 +2,8 @@ package dockfmt
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/hashicorp/go-multierror"
@@ -18,7 +20,7 @@ func TestIdentifyFormatWithEmptyFormatProvider(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(&bytes.Buffer{})
 
-	format, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
 	_, ok := e.(UnknownFormatError)
 	assert.True(t, ok)
 
@@ -38,7 +40,7 @@ func TestIdentifyFormatWithSingleNonMatchingFormat(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(&bytes.Buffer{})
 
-	format, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
 
 	assert.Nil(t, format)
 
@@ -57,7 +59,7 @@ func TestIdentifyFormatWithSingleMatchingFormat(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(&bytes.Buffer{})
 
-	format, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
 
 	assert.Nil(t, e)
 	assert.Equal(t, formatMock, format)
@@ -84,7 +86,7 @@ func TestIdentifyFormatWithSingleMatchingAndSeveralNonMatchingFormats(t *testing
 	logger := logrus.New()
 	logger.SetOutput(&bytes.Buffer{})
 
-	format, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
 
 	assert.Equal(t, matchingFormatMock, format)
 	multiError, ok := e.(*multierror.Error)
@@ -121,7 +123,7 @@ func TestIdentifyFormatWithSeveralMatchingAndSeveralNonMatchingFormats(t *testin
 	logger := logrus.New()
 	logger.SetOutput(&bytes.Buffer{})
 
-	format, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("not a dockerfile"), "filename")
 
 	assert.Nil(t, format)
 	assert.NotNil(t, e)
@@ -131,6 +133,168 @@ func TestIdentifyFormatWithSeveralMatchingAndSeveralNonMatchingFormats(t *testin
 
 	assert.Contains(t, ambiguousFormatError.Formats, matchingFormatMock1)
 	assert.Contains(t, ambiguousFormatError.Formats, matchingFormatMock2)
+	assert.Equal(t, "Ambiguous format, the input is valid for matchingFormatMock1, matchingFormatMock2", e.Error())
+}
+
+func TestIdentifyFormatPrefersFormatMatchingFilename(t *testing.T) {
+	matchingFormatMock := new(FormatMock)
+	matchingFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	matchingFormatMock.On("Name").Return("matchingFormatMock")
+
+	filenameFormatMock := new(FilenameFormatMock)
+	filenameFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	filenameFormatMock.On("Name").Return("filenameFormatMock")
+	filenameFormatMock.On("MatchesFilename", "Containerfile").Return(true)
+
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{
+		matchingFormatMock,
+		filenameFormatMock,
+	})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("content"), "Containerfile")
+
+	assert.Nil(t, e)
+	assert.Equal(t, filenameFormatMock, format)
+}
+
+func TestIdentifyFormatIsAmbiguousWhenFilenameDoesNotMatch(t *testing.T) {
+	matchingFormatMock := new(FormatMock)
+	matchingFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	matchingFormatMock.On("Name").Return("matchingFormatMock")
+
+	filenameFormatMock := new(FilenameFormatMock)
+	filenameFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	filenameFormatMock.On("Name").Return("filenameFormatMock")
+	filenameFormatMock.On("MatchesFilename", "other").Return(false)
+
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{
+		matchingFormatMock,
+		filenameFormatMock,
+	})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	format, _, e := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("content"), "other")
+
+	assert.Nil(t, format)
+	_, ok := e.(AmbiguousFormatError)
+	assert.True(t, ok)
+}
+
+func TestValidateNamedFormatSkipsIdentification(t *testing.T) {
+	otherFormatMock := new(FormatMock)
+	otherFormatMock.On("Name").Return("other")
+
+	namedFormatMock := new(FormatMock)
+	namedFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	namedFormatMock.On("Name").Return("Named")
+
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{
+		otherFormatMock,
+		namedFormatMock,
+	})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	format, document, e := ValidateNamedFormat(logger, formatProviderMock, "named", bytes.NewBufferString("content"), "filename")
+
+	assert.Nil(t, e)
+	assert.Equal(t, namedFormatMock, format)
+	assert.NotNil(t, document)
+	otherFormatMock.AssertNotCalled(t, "ValidateInput", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestValidateNamedFormatReportsInvalidContentAsUnknownFormat(t *testing.T) {
+	namedFormatMock := new(FormatMock)
+	namedFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("invalid"))
+	namedFormatMock.On("Name").Return("named")
+
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{namedFormatMock})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	format, _, e := ValidateNamedFormat(logger, formatProviderMock, "named", bytes.NewBufferString("content"), "filename")
+
+	assert.Nil(t, format)
+	_, ok := e.(UnknownFormatError)
+	assert.True(t, ok)
+}
+
+func TestFormatByNameFailsForUnknownName(t *testing.T) {
+	formatMock := new(FormatMock)
+	formatMock.On("Name").Return("known")
+
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{formatMock})
+
+	format, e := FormatByName(formatProviderMock, "unknown")
+
+	assert.Nil(t, format)
+	assert.EqualError(t, e, "Unknown format unknown, known formats are known")
+}
+
+func TestIdentifyFormatEveryFormatSeesFullContent(t *testing.T) {
+	contents := make([]string, 0)
+	readAll := func(args mock.Arguments) {
+		content, err := ioutil.ReadAll(args.Get(1).(io.Reader))
+		assert.Nil(t, err)
+		contents = append(contents, string(content))
+	}
+
+	nonMatchingFormatMock := new(FormatMock)
+	nonMatchingFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("error")).Run(readAll)
+	nonMatchingFormatMock.On("Name").Return("nonMatchingFormatMock")
+
+	matchingFormatMock := new(FormatMock)
+	matchingFormatMock.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(readAll)
+	matchingFormatMock.On("Name").Return("matchingFormatMock")
+
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{nonMatchingFormatMock, matchingFormatMock})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	// stdin can only be read once
+	reader := ioutil.NopCloser(bytes.NewBufferString("FROM scratch"))
+	format, document, _ := IdentifyFormat(logger, formatProviderMock, reader, "-")
+
+	assert.Equal(t, matchingFormatMock, format)
+	assert.Equal(t, []string{"FROM scratch", "FROM scratch"}, contents)
+	assert.Equal(t, &FormatMockDocument{format: matchingFormatMock}, document)
+}
+
+func TestIdentifyFormatReportsReadErrors(t *testing.T) {
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	expected := errors.New("expected")
+	format, document, e := IdentifyFormat(logger, formatProviderMock, failingReader{err: expected}, "filename")
+
+	assert.Nil(t, format)
+	assert.Nil(t, document)
+	assert.Equal(t, expected, e)
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
 }
 
 func TestDefaultFormatProviderExits(t *testing.T) {
@@ -2,7 +2,6 @@ package dockfmt
 
 import (
 	"bytes"
-	"strings"
 	"testing"
 
 	"github.com/MeneDev/dockmoor/dockref"
@@ -12,35 +11,35 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-func TestFormatProcessor_ProcessPassesLogAndReaderAndImageProcessor(t *testing.T) {
+func TestFormatProcessor_ProcessPassesLogAndImageProcessorToDocument(t *testing.T) {
 	log := logrus.New()
 
 	formatMock := new(FormatMock)
-	reader := strings.NewReader("input")
+	document := &FormatMockDocument{format: formatMock}
 	processorFx := func(r dockref.Reference) (dockref.Reference, error) {
 		return r, nil
 	}
-	formatMock.On("Process", log, reader, bytes.NewBuffer(nil), mock.Anything).Return(nil)
+	formatMock.On("Process", log, bytes.NewBuffer(nil), mock.Anything).Return(nil)
 
-	processor := FormatProcessorNew(formatMock, log, reader)
+	processor := FormatProcessorNew(formatMock, log, document)
 
 	processor.Process(processorFx)
 
 	formatMock.Mock.AssertNumberOfCalls(t, "Process", 1)
 }
 
-func TestFormatProcessor_ProcessPassesLogAndReaderAndImageProcessorAndWriter(t *testing.T) {
+func TestFormatProcessor_ProcessPassesLogAndImageProcessorToDocumentAndWriter(t *testing.T) {
 	log := logrus.New()
 
 	formatMock := new(FormatMock)
-	reader := strings.NewReader("input")
+	document := &FormatMockDocument{format: formatMock}
 	processorFx := func(r dockref.Reference) (dockref.Reference, error) {
 		return r, nil
 	}
 	writer := bytes.NewBufferString("writer")
-	formatMock.On("Process", log, reader, writer, mock.Anything).Return(nil)
+	formatMock.On("Process", log, writer, mock.Anything).Return(nil)
 
-	processor := FormatProcessorNew(formatMock, log, reader).WithWriter(writer)
+	processor := FormatProcessorNew(formatMock, log, document).WithWriter(writer)
 
 	processor.Process(processorFx)
 
@@ -56,3 +55,31 @@ func TestFormatError(t *testing.T) {
 
 	assert.Equal(t, "FormatError: test", formatError.Error())
 }
+
+func TestFormatProcessor_ProcessLocatedAddsFilename(t *testing.T) {
+	log := logrus.New()
+
+	formatMock := new(FormatMock)
+	document := &FormatMockDocument{format: formatMock}
+	formatMock.On("Process", log, bytes.NewBuffer(nil), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		imageNameProcessor := args.Get(2).(LocatedImageNameProcessor)
+		_, _ = imageNameProcessor(dockref.MustParse("nginx"), Location{Line: 2, Column: 6, Instruction: "FROM"})
+	})
+
+	var location Location
+	processor := FormatProcessorNew(formatMock, log, document).WithFilename("Dockerfile")
+	err := processor.ProcessLocated(func(r dockref.Reference, l Location) (dockref.Reference, error) {
+		location = l
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, Location{File: "Dockerfile", Line: 2, Column: 6, Instruction: "FROM"}, location)
+}
+
+func TestLocation_String(t *testing.T) {
+	assert.Equal(t, "Dockerfile:2:6", Location{File: "Dockerfile", Line: 2, Column: 6}.String())
+	assert.Equal(t, "Dockerfile:2", Location{File: "Dockerfile", Line: 2}.String())
+	assert.Equal(t, "Dockerfile", Location{File: "Dockerfile", Column: 6}.String())
+	assert.Equal(t, "", Location{}.String())
+}
@@ -0,0 +1,126 @@
+package github
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*githubFormat)(nil)
+
+// dockerPrefix marks image references in the uses key of steps, e.g. uses: docker://alpine:3.8
+const dockerPrefix = "docker://"
+
+type githubFormat struct {
+}
+
+func (format *githubFormat) Name() string {
+	return "github-actions"
+}
+
+func New() dockfmt.Format {
+	return newGithubFormat()
+}
+
+func newGithubFormat() *githubFormat {
+	return new(githubFormat)
+}
+
+func (format *githubFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *githubFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	jobs := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, "jobs"))
+	if jobs == nil {
+		return nil, errors.Errorf("No jobs found")
+	}
+	if jobs.Kind != yaml.MappingNode || len(jobs.Content) == 0 {
+		return nil, errors.Errorf("jobs is not a mapping of jobs")
+	}
+
+	// other formats like CircleCI also define jobs, but only workflows define where they run
+	for i := 1; i < len(jobs.Content); i += 2 {
+		job := yamlfmt.ResolveAlias(jobs.Content[i])
+		name := jobs.Content[i-1].Value
+		if job.Kind != yaml.MappingNode {
+			return nil, errors.Errorf("Job %s is not a mapping", name)
+		}
+
+		if yamlfmt.MappingValue(job, "runs-on") == nil && yamlfmt.MappingValue(job, "uses") == nil {
+			return nil, errors.Errorf("Job %s has neither runs-on nor uses", name)
+		}
+	}
+
+	return yamlfmt.DocumentNew(content, imageNodes(jobs)), nil
+}
+
+// imageNodes returns the container, services and docker steps of all jobs in document order
+func imageNodes(jobs *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	for i := 1; i < len(jobs.Content); i += 2 {
+		prefix := "jobs." + jobs.Content[i-1].Value + "."
+		job := jobs.Content[i]
+
+		// the container is either the image or a mapping with the image
+		if container := yamlfmt.ResolveAlias(yamlfmt.MappingValue(job, "container")); container != nil {
+			if container.Kind == yaml.MappingNode {
+				images = append(images, yamlfmt.Image{
+					Node:        yamlfmt.MappingValue(container, "image"),
+					Instruction: prefix + "container.image",
+				})
+			} else {
+				images = append(images, yamlfmt.Image{
+					Node:        container,
+					Instruction: prefix + "container",
+				})
+			}
+		}
+
+		services := yamlfmt.ResolveAlias(yamlfmt.MappingValue(job, "services"))
+		if services != nil && services.Kind == yaml.MappingNode {
+			for j := 1; j < len(services.Content); j += 2 {
+				images = append(images, yamlfmt.Image{
+					Node:        yamlfmt.MappingValue(services.Content[j], "image"),
+					Instruction: prefix + "services." + services.Content[j-1].Value + ".image",
+				})
+			}
+		}
+
+		steps := yamlfmt.ResolveAlias(yamlfmt.MappingValue(job, "steps"))
+		if steps != nil && steps.Kind == yaml.SequenceNode {
+			for j, step := range steps.Content {
+				images = append(images, yamlfmt.Image{
+					Node:        yamlfmt.MappingValue(step, "uses"),
+					Prefix:      dockerPrefix,
+					Instruction: prefix + "steps[" + strconv.Itoa(j) + "].uses",
+				})
+			}
+		}
+	}
+
+	return images
+}
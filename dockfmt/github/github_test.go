@@ -0,0 +1,237 @@
+package github
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestGithubName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "github-actions", name)
+}
+
+func TestGithubFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGithubFormatWithoutJobsIsInvalid(t *testing.T) {
+	file := `on: push`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGithubFormatJobsSequenceIsInvalid(t *testing.T) {
+	file := `on: push
+jobs:
+  - build`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGithubFormatCircleciJobIsInvalid(t *testing.T) {
+	file := `version: 2.1
+jobs:
+  build:
+    docker:
+      - image: golang
+    steps:
+      - checkout`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGithubFormatJobsAreValid(t *testing.T) {
+	files := map[string]string{
+		"runs-on": `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: make`,
+		"uses": `on: push
+jobs:
+  call:
+    uses: octo-org/example-repo/.github/workflows/reusable.yml@v1`,
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Nil(t, valid)
+		})
+	}
+}
+
+func TestGithubCallsProcessorForEveryImage(t *testing.T) {
+	file := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container:
+      image: node:10
+      env:
+        NODE_ENV: development
+    services:
+      redis:
+        image: redis:5
+      postgres:
+        image: postgres:11
+    steps:
+      - uses: actions/checkout@v1
+      - uses: docker://alpine:3.8
+        with:
+          args: echo hello
+  test:
+    runs-on: ubuntu-latest
+    container: golang:1.12
+    steps:
+      - run: make test
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node:10", "redis:5", "postgres:11", "alpine:3.8", "golang:1.12"}, images)
+}
+
+func TestGithubReportsLocations(t *testing.T) {
+	file := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: node
+    services:
+      redis:
+        image: redis
+    steps:
+      - uses: docker://alpine`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 5, Column: 16, Instruction: "jobs.build.container"},
+		{Line: 8, Column: 16, Instruction: "jobs.build.services.redis.image"},
+		{Line: 10, Column: 24, Instruction: "jobs.build.steps[0].uses"},
+	}, locations)
+}
+
+func TestGithubUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# a comment
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: node   # trailing comment
+    steps:
+      - uses: docker://alpine
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestGithubRewritesImagesInPlace(t *testing.T) {
+	file := `# a comment
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container:
+      image: node:10   # trailing comment
+    services:
+      redis:
+        image: "redis"
+    steps:
+      - uses: actions/checkout@v1
+      - uses: 'docker://alpine:3.8'
+`
+	expected := `# a comment
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container:
+      image: node:pinned   # trailing comment
+    services:
+      redis:
+        image: "redis:pinned"
+    steps:
+      - uses: actions/checkout@v1
+      - uses: 'docker://alpine:pinned'
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestGithubSkipsExpressions(t *testing.T) {
+	file := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: ${{ matrix.image }}
+    services:
+      redis:
+        image: redis`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"redis"}, images)
+}
+
+func TestGithubPassProcessorErrors(t *testing.T) {
+	file := `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: node`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
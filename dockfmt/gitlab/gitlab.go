@@ -0,0 +1,170 @@
+package gitlab
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*gitlabFormat)(nil)
+
+// keywords are the top level keys of .gitlab-ci.yml that are not jobs
+var keywords = map[string]struct{}{
+	"image":         {},
+	"services":      {},
+	"stages":        {},
+	"types":         {},
+	"before_script": {},
+	"after_script":  {},
+	"variables":     {},
+	"cache":         {},
+	"include":       {},
+	"default":       {},
+	"workflow":      {},
+}
+
+// jobKeys are keys of which at least one is used by every job
+var jobKeys = []string{"script", "trigger", "extends"}
+
+type gitlabFormat struct {
+}
+
+func (format *gitlabFormat) Name() string {
+	return "gitlab-ci"
+}
+
+func New() dockfmt.Format {
+	return newGitlabFormat()
+}
+
+func newGitlabFormat() *gitlabFormat {
+	return new(gitlabFormat)
+}
+
+func (format *gitlabFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *gitlabFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	// docker-compose files use a mapping of services, GitLab CI a sequence
+	if services := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, "services")); services != nil && services.Kind == yaml.MappingNode {
+		return nil, errors.Errorf("services is a mapping")
+	}
+
+	// .travis.yml files define the language and can use script in deploy
+	if yamlfmt.MappingValue(root, "language") != nil {
+		return nil, errors.Errorf("language is not a GitLab CI keyword")
+	}
+
+	if len(jobs(root)) == 0 {
+		return nil, errors.Errorf("No jobs found")
+	}
+
+	return yamlfmt.DocumentNew(content, imageNodes(root)), nil
+}
+
+type job struct {
+	name string
+	node *yaml.Node
+}
+
+// jobs returns the jobs and hidden jobs (templates, starting with a dot) in document order
+func jobs(root *yaml.Node) []job {
+	result := make([]job, 0)
+	for i := 1; i < len(root.Content); i += 2 {
+		key := root.Content[i-1]
+		if _, ok := keywords[key.Value]; ok || key.Tag == "!!merge" {
+			continue
+		}
+
+		node := yamlfmt.ResolveAlias(root.Content[i])
+		if node.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if strings.HasPrefix(key.Value, ".") || isJob(node) {
+			result = append(result, job{name: key.Value, node: node})
+		}
+	}
+	return result
+}
+
+func isJob(node *yaml.Node) bool {
+	for _, jobKey := range jobKeys {
+		if yamlfmt.MappingValue(node, jobKey) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// imageNodes returns the image and services of the global defaults, the default section and all jobs
+func imageNodes(root *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	images = append(images, jobImages("", root)...)
+
+	if defaults := yamlfmt.MappingValue(root, "default"); defaults != nil {
+		images = append(images, jobImages("default.", defaults)...)
+	}
+
+	for _, j := range jobs(root) {
+		images = append(images, jobImages(j.name+".", j.node)...)
+	}
+
+	return images
+}
+
+// jobImages returns the image and services of a job, the default section or the global defaults.
+// Both are either a string or a mapping with the image in the name key.
+func jobImages(prefix string, job *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+
+	if image := yamlfmt.MappingValue(job, "image"); image != nil {
+		images = append(images, imageName(prefix+"image", image)...)
+	}
+
+	services := yamlfmt.ResolveAlias(yamlfmt.MappingValue(job, "services"))
+	if services != nil && services.Kind == yaml.SequenceNode {
+		for i, service := range services.Content {
+			images = append(images, imageName(prefix+"services["+strconv.Itoa(i)+"]", service)...)
+		}
+	}
+
+	return images
+}
+
+func imageName(instruction string, node *yaml.Node) []yamlfmt.Image {
+	node = yamlfmt.ResolveAlias(node)
+	if node.Kind == yaml.MappingNode {
+		name := yamlfmt.MappingValue(node, "name")
+		if name == nil {
+			return nil
+		}
+		return []yamlfmt.Image{{Node: name, Instruction: instruction + ".name"}}
+	}
+	return []yamlfmt.Image{{Node: node, Instruction: instruction}}
+}
@@ -0,0 +1,239 @@
+package gitlab
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestGitlabName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "gitlab-ci", name)
+}
+
+func TestGitlabFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGitlabFormatDockerfileIsInvalid(t *testing.T) {
+	file := `FROM nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGitlabFormatComposeIsInvalid(t *testing.T) {
+	file := `version: "3"
+services:
+  web:
+    image: nginx
+    extends: base`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGitlabFormatTravisIsInvalid(t *testing.T) {
+	file := `language: go
+deploy:
+  provider: script
+  script: make deploy`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGitlabFormatWithoutJobsIsInvalid(t *testing.T) {
+	file := `image: nginx
+stages:
+  - build`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestGitlabFormatJobsAreValid(t *testing.T) {
+	files := map[string]string{
+		"script": `build:
+  script: make`,
+		"trigger": `deploy:
+  trigger: other/project`,
+		"extends": `build:
+  extends: .template`,
+		"template": `.template:
+  image: golang`,
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Nil(t, valid)
+		})
+	}
+}
+
+func TestGitlabCallsProcessorForEveryImage(t *testing.T) {
+	file := `image: ruby:2.6
+services:
+  - postgres:11
+
+default:
+  image: golang:1.12
+  services:
+    - name: redis:5
+      alias: cache
+
+stages:
+  - build
+
+.template:
+  image:
+    name: alpine:3.9
+    entrypoint: [""]
+
+build:
+  extends: .template
+  services:
+    - docker:dind
+  script: make
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ruby:2.6", "postgres:11", "golang:1.12", "redis:5", "alpine:3.9", "docker:dind"}, images)
+}
+
+func TestGitlabReportsLocations(t *testing.T) {
+	file := `image: ruby
+default:
+  services:
+    - name: "redis"
+build:
+  image: {name: golang}
+  script: make`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 1, Column: 8, Instruction: "image"},
+		{Line: 4, Column: 14, Instruction: "default.services[0].name"},
+		{Line: 6, Column: 17, Instruction: "build.image.name"},
+	}, locations)
+}
+
+func TestGitlabUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# a comment
+build:
+  image: golang   # trailing comment
+  script:
+    - make
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestGitlabRewritesImagesInPlace(t *testing.T) {
+	file := `# a comment
+.defaults: &defaults
+  image: &img golang   # trailing comment
+  services:
+    - 'postgres'
+
+build:
+  <<: *defaults
+  script: make
+
+test:
+  image: *img
+  services:
+    - name: "redis"
+  script: make test
+`
+	expected := `# a comment
+.defaults: &defaults
+  image: &img golang:pinned   # trailing comment
+  services:
+    - 'postgres:pinned'
+
+build:
+  <<: *defaults
+  script: make
+
+test:
+  image: *img
+  services:
+    - name: "redis:pinned"
+  script: make test
+`
+	calls := 0
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		calls++
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, expected, out)
+}
+
+func TestGitlabSkipsVariables(t *testing.T) {
+	file := `build:
+  image: $CI_REGISTRY_IMAGE/builder
+  services:
+    - postgres
+  script: make`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"postgres"}, images)
+}
+
+func TestGitlabPassProcessorErrors(t *testing.T) {
+	file := `build:
+  image: golang
+  script: make`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
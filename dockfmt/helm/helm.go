@@ -0,0 +1,111 @@
+package helm
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*helmFormat)(nil)
+
+type helmFormat struct {
+}
+
+func (format *helmFormat) Name() string {
+	return "helm-values"
+}
+
+func New() dockfmt.Format {
+	return newHelmFormat()
+}
+
+func newHelmFormat() *helmFormat {
+	return new(helmFormat)
+}
+
+func (format *helmFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *helmFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	// kubernetes objects can contain image blocks in custom resources
+	if yamlfmt.MappingValue(root, "apiVersion") != nil && yamlfmt.MappingValue(root, "kind") != nil {
+		return nil, errors.Errorf("Kubernetes objects are not values")
+	}
+
+	// values files have no required keys, they are only identified by the image blocks
+	images := imageBlocks("", root)
+	if len(images) == 0 {
+		return nil, errors.Errorf("No image blocks found")
+	}
+
+	return yamlfmt.DocumentNew(content, nil).WithSplitImages(images), nil
+}
+
+// isImageBlock returns true for mappings with a repository and at least a tag, digest or registry
+func isImageBlock(node *yaml.Node) bool {
+	repository := yamlfmt.ResolveAlias(yamlfmt.MappingValue(node, "repository"))
+	if repository == nil || repository.Kind != yaml.ScalarNode {
+		return false
+	}
+	for _, key := range []string{"tag", "digest", "registry"} {
+		if yamlfmt.MappingValue(node, key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// imageBlocks returns the image blocks below node in document order, aliases are not followed
+func imageBlocks(path string, node *yaml.Node) []yamlfmt.SplitImage {
+	images := make([]yamlfmt.SplitImage, 0)
+	switch node.Kind {
+	case yaml.MappingNode:
+		if isImageBlock(node) {
+			return append(images, yamlfmt.SplitImage{
+				Mapping:     node,
+				Domain:      yamlfmt.MappingValue(node, "registry"),
+				Name:        yamlfmt.MappingValue(node, "repository"),
+				Tag:         yamlfmt.MappingValue(node, "tag"),
+				Digest:      yamlfmt.MappingValue(node, "digest"),
+				DigestKey:   "digest",
+				Instruction: path,
+			})
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			key := node.Content[i-1].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			images = append(images, imageBlocks(key, node.Content[i])...)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			images = append(images, imageBlocks(path+"["+strconv.Itoa(i)+"]", item)...)
+		}
+	}
+	return images
+}
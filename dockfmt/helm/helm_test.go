@@ -0,0 +1,277 @@
+package helm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const digest = "sha256:2c4269d573d9fc6e9e95d4ad1c5b5ba5ac2b2b61d9ba0d2c2c5a5f5d0a7b1e0f"
+
+func TestHelmName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "helm-values", name)
+}
+
+func TestHelmFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestHelmFormatWithoutImageBlocksIsInvalid(t *testing.T) {
+	file := `replicaCount: 1
+image: nginx:1.15
+service:
+  repository: not-an-image`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestHelmFormatKubernetesObjectIsInvalid(t *testing.T) {
+	file := `apiVersion: example.com/v1
+kind: Custom
+spec:
+  image:
+    repository: nginx
+    tag: "1.15"`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestHelmFormatImageBlockIsValid(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: "1.15"`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, valid)
+}
+
+func TestHelmCallsProcessorForEveryImage(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: "1.15"
+metrics:
+  image:
+    registry: docker.io
+    repository: bitnami/nginx-exporter
+    tag: 0.1.0
+    digest: ` + digest + `
+sidecars:
+  - name: envoy
+    image:
+      repository: envoyproxy/envoy
+      tag: v1.10.0
+other:
+  repository: quay.io/prometheus/busybox
+  digest: ` + digest + `
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"nginx:1.15",
+		"docker.io/bitnami/nginx-exporter:0.1.0@" + digest,
+		"envoyproxy/envoy:v1.10.0",
+		"quay.io/prometheus/busybox@" + digest,
+	}, images)
+}
+
+func TestHelmReportsLocations(t *testing.T) {
+	file := `image:
+  repository: "nginx"
+  tag: "1.15"
+sidecars:
+  - image: {repository: envoy, tag: v1}`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 2, Column: 16, Instruction: "image"},
+		{Line: 5, Column: 25, Instruction: "sidecars[0].image"},
+	}, locations)
+}
+
+func TestHelmUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# values
+image:
+  registry: docker.io
+  repository: bitnami/nginx   # comment
+  tag: '1.15'
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestHelmRewritesTagsAndAddsDigests(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: "1.15"   # keep me
+  pullPolicy: IfNotPresent
+sidecars:
+  - repository: envoy
+    tag: v1
+  - name: busybox
+    image:
+      repository: busybox
+      tag: latest
+      digest: ""
+`
+	expected := `image:
+  repository: nginx
+  tag: "pinned"   # keep me
+  digest: ` + digest + `
+  pullPolicy: IfNotPresent
+sidecars:
+  - repository: envoy
+    tag: pinned
+    digest: ` + digest + `
+  - name: busybox
+    image:
+      repository: busybox
+      tag: pinned
+      digest: "` + digest + `"
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithDigest(digest).WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag | dockref.FormatHasDigest)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestHelmAddsDigestAtEndOfFileWithoutNewline(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: "1.15"`
+	expected := `image:
+  repository: nginx
+  tag: "1.15"
+  digest: ` + digest + `
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithDigest(digest).WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag | dockref.FormatHasDigest)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestHelmRewritesRegistryAndRepository(t *testing.T) {
+	file := `image:
+  registry: docker.io
+  repository: bitnami/nginx
+  tag: "1.15"
+`
+	expected := `image:
+  registry: quay.io
+  repository: mirror/nginx
+  tag: "1.15"
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return dockref.MustParse("quay.io/mirror/nginx:1.15"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestHelmSkipsFlowMappingsWithoutDigest(t *testing.T) {
+	file := `images:
+  - {repository: redis, tag: "7"}
+  - {repository: nginx, tag: "1.15", digest: ""}
+  - repository: alpine
+    tag: "3.18"
+`
+	expected := `images:
+  - {repository: redis, tag: "7"}
+  - {repository: nginx, tag: "1.15", digest: "` + digest + `"}
+  - repository: alpine
+    tag: "3.18"
+    digest: ` + digest + `
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithDigest(digest).WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag | dockref.FormatHasDigest)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestHelmSkipsEmptyTagsWithoutDigest(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: ""
+sidecar:
+  repository: busybox
+  tag: ""
+  digest: ` + digest + `
+`
+	images := make([]string, 0)
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+	assert.Equal(t, []string{"busybox@" + digest}, images)
+}
+
+func TestHelmSkipsTemplatedValues(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: "{{ .Chart.AppVersion }}"
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestHelmPassProcessorErrors(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: "1.15"`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
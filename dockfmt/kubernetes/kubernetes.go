@@ -0,0 +1,166 @@
+package kubernetes
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*kubernetesFormat)(nil)
+
+// podSpecPaths are the paths to the pod spec for each kind of workload
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// containerKeys are the keys of the pod spec that contain lists of containers
+var containerKeys = map[string]struct{}{
+	"initContainers":      {},
+	"containers":          {},
+	"ephemeralContainers": {},
+}
+
+type kubernetesFormat struct {
+}
+
+func (format *kubernetesFormat) Name() string {
+	return "kubernetes"
+}
+
+func New() dockfmt.Format {
+	return newKubernetesFormat()
+}
+
+func newKubernetesFormat() *kubernetesFormat {
+	return new(kubernetesFormat)
+}
+
+func (format *kubernetesFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *kubernetesFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, roots, err := yamlfmt.ParseAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, root := range roots {
+		err = validateObject(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	images := make([]yamlfmt.Image, 0)
+	for _, root := range roots {
+		images = append(images, objectImages(root)...)
+	}
+
+	return yamlfmt.DocumentNew(content, images), nil
+}
+
+// validateObject checks that the document in line of root is a kubernetes object
+func validateObject(root *yaml.Node) error {
+	if root.Kind != yaml.MappingNode {
+		return errors.Errorf("Document in line %d is not a mapping", root.Line)
+	}
+
+	if yamlfmt.MappingValue(root, "apiVersion") == nil || yamlfmt.MappingValue(root, "kind") == nil {
+		return errors.Errorf("Document in line %d has no apiVersion or kind", root.Line)
+	}
+
+	// kustomizations look like objects but are handled by the kustomize format
+	if strings.HasPrefix(scalarValue(yamlfmt.MappingValue(root, "apiVersion")), "kustomize.config.k8s.io/") {
+		return errors.Errorf("Document in line %d is a kustomization", root.Line)
+	}
+
+	return nil
+}
+
+func scalarValue(node *yaml.Node) string {
+	node = yamlfmt.ResolveAlias(node)
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// objectImages returns the images of all containers of a workload, the items of a List are searched recursively
+func objectImages(object *yaml.Node) []yamlfmt.Image {
+	kind := scalarValue(yamlfmt.MappingValue(object, "kind"))
+
+	if kind == "List" {
+		images := make([]yamlfmt.Image, 0)
+		items := yamlfmt.ResolveAlias(yamlfmt.MappingValue(object, "items"))
+		if items != nil && items.Kind == yaml.SequenceNode {
+			for _, item := range items.Content {
+				images = append(images, objectImages(item)...)
+			}
+		}
+		return images
+	}
+
+	path, ok := podSpecPaths[kind]
+	if !ok {
+		return nil
+	}
+
+	podSpec := object
+	for _, key := range path {
+		podSpec = yamlfmt.MappingValue(podSpec, key)
+	}
+
+	prefix := kind + "/" + scalarValue(yamlfmt.MappingValue(yamlfmt.MappingValue(object, "metadata"), "name"))
+	for _, key := range path {
+		prefix += "." + key
+	}
+
+	podSpec = yamlfmt.ResolveAlias(podSpec)
+	if podSpec == nil || podSpec.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	// in document order
+	images := make([]yamlfmt.Image, 0)
+	for j := 1; j < len(podSpec.Content); j += 2 {
+		key := podSpec.Content[j-1].Value
+		if _, ok := containerKeys[key]; !ok {
+			continue
+		}
+		containers := yamlfmt.ResolveAlias(podSpec.Content[j])
+		if containers.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, container := range containers.Content {
+			images = append(images, yamlfmt.Image{
+				Node:        yamlfmt.MappingValue(container, "image"),
+				Instruction: prefix + "." + key + "[" + strconv.Itoa(i) + "].image",
+			})
+		}
+	}
+	return images
+}
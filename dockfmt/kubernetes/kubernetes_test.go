@@ -0,0 +1,263 @@
+package kubernetes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestKubernetesName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "kubernetes", name)
+}
+
+func TestKubernetesFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKubernetesFormatComposeIsInvalid(t *testing.T) {
+	file := `version: "3"
+services:
+  web:
+    image: nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKubernetesFormatDocumentWithoutKindIsInvalid(t *testing.T) {
+	file := `apiVersion: v1
+kind: Service
+---
+apiVersion: apps/v1
+spec: {}`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKubernetesFormatKustomizationIsInvalid(t *testing.T) {
+	file := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+images:
+  - name: nginx
+    newTag: "1.15"`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKubernetesFormatObjectsAreValid(t *testing.T) {
+	file := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+---
+`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, valid)
+}
+
+func TestKubernetesCallsProcessorForEveryImage(t *testing.T) {
+	file := `apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+spec:
+  containers:
+    - name: app
+      image: pod:1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.15
+        - name: sidecar
+          image: envoy:1.10
+      initContainers:
+        - name: init
+          image: busybox:1.30
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: StatefulSet
+spec:
+  template:
+    spec:
+      containers:
+        - image: postgres:11
+---
+apiVersion: apps/v1
+kind: DaemonSet
+spec:
+  template:
+    spec:
+      containers:
+        - image: fluentd:1
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      containers:
+        - image: job:1
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - image: cron:1
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Pod
+    spec:
+      ephemeralContainers:
+        - image: debug:1
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"pod:1",
+		"nginx:1.15", "envoy:1.10", "busybox:1.30",
+		"postgres:11",
+		"fluentd:1",
+		"job:1",
+		"cron:1",
+		"debug:1",
+	}, images)
+}
+
+func TestKubernetesReportsLocations(t *testing.T) {
+	file := `apiVersion: v1
+kind: Service
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+        - image: "busybox"
+      containers:
+        - {name: web, image: nginx}`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 12, Column: 19, Instruction: "Deployment/web.spec.template.spec.initContainers[0].image"},
+		{Line: 14, Column: 30, Instruction: "Deployment/web.spec.template.spec.containers[0].image"},
+	}, locations)
+}
+
+func TestKubernetesRewritesImagesInPlace(t *testing.T) {
+	file := `# a comment
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.15   # trailing comment
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      containers:
+        - image: 'job'
+`
+	expected := `# a comment
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:pinned   # trailing comment
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      containers:
+        - image: 'job:pinned'
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestKubernetesPassProcessorErrors(t *testing.T) {
+	file := `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+    - image: nginx`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
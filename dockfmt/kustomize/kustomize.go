@@ -0,0 +1,163 @@
+package kustomize
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*kustomizeFormat)(nil)
+
+// apiGroup of Kustomization and Component
+const apiGroup = "kustomize.config.k8s.io/"
+
+// kustomizationFields are the top level keys of a kustomization, used to identify files without apiVersion and kind
+var kustomizationFields = map[string]struct{}{
+	"apiVersion":            {},
+	"kind":                  {},
+	"metadata":              {},
+	"namespace":             {},
+	"namePrefix":            {},
+	"nameSuffix":            {},
+	"commonLabels":          {},
+	"commonAnnotations":     {},
+	"labels":                {},
+	"resources":             {},
+	"bases":                 {},
+	"components":            {},
+	"crds":                  {},
+	"images":                {},
+	"replicas":              {},
+	"replacements":          {},
+	"vars":                  {},
+	"patches":               {},
+	"patchesJson6902":       {},
+	"patchesStrategicMerge": {},
+	"configMapGenerator":    {},
+	"secretGenerator":       {},
+	"generatorOptions":      {},
+	"generators":            {},
+	"transformers":          {},
+	"validators":            {},
+	"configurations":        {},
+	"openapi":               {},
+	"helmGlobals":           {},
+	"helmCharts":            {},
+	"buildMetadata":         {},
+	"sortOptions":           {},
+}
+
+type kustomizeFormat struct {
+}
+
+func (format *kustomizeFormat) Name() string {
+	return "kustomize"
+}
+
+func New() dockfmt.Format {
+	return newKustomizeFormat()
+}
+
+func newKustomizeFormat() *kustomizeFormat {
+	return new(kustomizeFormat)
+}
+
+func (format *kustomizeFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *kustomizeFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	err = validateKustomization(root)
+	if err != nil {
+		return nil, err
+	}
+
+	images := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, "images"))
+	if images != nil && images.Kind != yaml.SequenceNode {
+		return nil, errors.Errorf("images is not a list")
+	}
+
+	return yamlfmt.DocumentNew(content, nil).WithSplitImages(imageEntries(images)), nil
+}
+
+// validateKustomization checks the apiVersion and kind when present, otherwise every key has to be a field of a kustomization
+func validateKustomization(root *yaml.Node) error {
+	apiVersion := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, "apiVersion"))
+	kind := yamlfmt.ResolveAlias(yamlfmt.MappingValue(root, "kind"))
+
+	if apiVersion != nil || kind != nil {
+		if apiVersion == nil || kind == nil || !strings.HasPrefix(apiVersion.Value, apiGroup) {
+			return errors.Errorf("Not a kustomization, apiVersion or kind is missing or not in %s", apiGroup)
+		}
+		return nil
+	}
+
+	if len(root.Content) == 0 {
+		return errors.Errorf("Empty mapping is not a kustomization")
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		if _, ok := kustomizationFields[key]; !ok {
+			return errors.Errorf("Unknown field %s in line %d", key, root.Content[i].Line)
+		}
+	}
+
+	return nil
+}
+
+// imageEntries returns the entries that set a tag or digest, the reference is newName or name with newTag and digest.
+// Entries that only change the name keep the tag of the resources, which is not known here.
+func imageEntries(images *yaml.Node) []yamlfmt.SplitImage {
+	entries := make([]yamlfmt.SplitImage, 0)
+	if images == nil {
+		return entries
+	}
+
+	for i, entry := range images.Content {
+		tag := yamlfmt.MappingValue(entry, "newTag")
+		digest := yamlfmt.MappingValue(entry, "digest")
+		if tag == nil && digest == nil {
+			continue
+		}
+
+		name := yamlfmt.MappingValue(entry, "newName")
+		if name == nil {
+			name = yamlfmt.MappingValue(entry, "name")
+		}
+
+		entries = append(entries, yamlfmt.SplitImage{
+			Mapping:     entry,
+			Name:        name,
+			Tag:         tag,
+			Digest:      digest,
+			DigestKey:   "digest",
+			Instruction: "images[" + strconv.Itoa(i) + "]",
+		})
+	}
+	return entries
+}
@@ -0,0 +1,207 @@
+package kustomize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const digest = "sha256:2c4269d573d9fc6e9e95d4ad1c5b5ba5ac2b2b61d9ba0d2c2c5a5f5d0a7b1e0f"
+
+func TestKustomizeName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "kustomize", name)
+}
+
+func TestKustomizeFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKustomizeFormatKubernetesObjectIsInvalid(t *testing.T) {
+	file := `apiVersion: apps/v1
+kind: Deployment
+spec: {}`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKustomizeFormatUnknownFieldIsInvalid(t *testing.T) {
+	file := `resources:
+  - deployment.yaml
+services:
+  web:
+    image: nginx`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKustomizeFormatImagesMustBeAList(t *testing.T) {
+	file := `images:
+  nginx: 1.15`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestKustomizeFormatKustomizationIsValid(t *testing.T) {
+	file := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - deployment.yaml`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, valid)
+}
+
+func TestKustomizeFormatWithoutApiVersionIsValid(t *testing.T) {
+	file := `namePrefix: dev-
+resources:
+  - ../base
+images:
+  - name: nginx
+    newTag: "1.15"`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, valid)
+}
+
+func TestKustomizeCallsProcessorForEveryImage(t *testing.T) {
+	file := `images:
+  - name: nginx
+    newTag: "1.15"
+  - name: postgres
+    newName: registry.example.com/postgres
+    newTag: "11"
+  - name: redis
+    digest: ` + digest + `
+  - name: busybox
+    newName: example/busybox
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"nginx:1.15",
+		"registry.example.com/postgres:11",
+		"redis@" + digest,
+	}, images)
+}
+
+func TestKustomizeReportsLocations(t *testing.T) {
+	file := `images:
+  - name: nginx
+    newTag: "1.15"
+  - {name: postgres, newName: "example/postgres", newTag: "11"}`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 2, Column: 11, Instruction: "images[0]"},
+		{Line: 4, Column: 32, Instruction: "images[1]"},
+	}, locations)
+}
+
+func TestKustomizeUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# overlay
+images:
+  - name: nginx   # comment
+    newTag: '1.15'
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestKustomizePinAddsDigests(t *testing.T) {
+	file := `resources:
+  - deployment.yaml
+images:
+  - name: nginx
+    newTag: "1.15"
+  - name: postgres
+    newName: example/postgres
+    newTag: "11"
+    digest: ""
+namePrefix: dev-
+`
+	expected := `resources:
+  - deployment.yaml
+images:
+  - name: nginx
+    newTag: "1.15"
+    digest: ` + digest + `
+  - name: postgres
+    newName: example/postgres
+    newTag: "11"
+    digest: "` + digest + `"
+namePrefix: dev-
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithDigest(digest).WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag | dockref.FormatHasDigest)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestKustomizeRewritesTags(t *testing.T) {
+	file := `images:
+  - name: nginx
+    newTag: "1.15"
+`
+	expected := `images:
+  - name: nginx
+    newTag: "1.15.8"
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("1.15.8").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestKustomizePassProcessorErrors(t *testing.T) {
+	file := `images:
+  - name: nginx
+    newTag: "1.15"`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
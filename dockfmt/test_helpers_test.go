@@ -49,18 +49,40 @@ func (m *FormatMock) OnName() *mock.Call {
 	return m.On("Name")
 }
 
-func (m *FormatMock) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+func (m *FormatMock) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (Document, error) {
 	called := m.Called(log, reader, filename)
-	return called.Error(0)
+	err := called.Error(0)
+	if err != nil {
+		return nil, err
+	}
+	return &FormatMockDocument{format: m}, nil
 }
 func (m *FormatMock) OnValidateInput(log interface{}, reader interface{}, filename interface{}) *mock.Call {
 	return m.On("ValidateInput", log, reader, filename)
 }
 
-func (m *FormatMock) Process(log logrus.FieldLogger, reader io.Reader, writer io.Writer, imageNameProcessor ImageNameProcessor) error {
-	called := m.Called(log, reader, writer, imageNameProcessor)
+func (m *FormatMock) OnProcess(log interface{}, writer interface{}, imageNameProcessor interface{}) *mock.Call {
+	return m.On("Process", log, writer, imageNameProcessor)
+}
+
+// FormatMockDocument is returned by FormatMock.ValidateInput, calls to Process are recorded by the FormatMock
+type FormatMockDocument struct {
+	format *FormatMock
+}
+
+func (d *FormatMockDocument) Process(log logrus.FieldLogger, writer io.Writer, imageNameProcessor LocatedImageNameProcessor) error {
+	called := d.format.MethodCalled("Process", log, writer, imageNameProcessor)
 	return called.Error(0)
 }
-func (m *FormatMock) OnProcess(log interface{}, reader interface{}, writer interface{}, imageNameProcessor interface{}) *mock.Call {
-	return m.On("Process", log, reader, writer, imageNameProcessor)
+
+var _ FilenameFormat = (*FilenameFormatMock)(nil)
+
+// FilenameFormatMock is a FormatMock with a naming convention
+type FilenameFormatMock struct {
+	FormatMock
+}
+
+func (m *FilenameFormatMock) MatchesFilename(filename string) bool {
+	called := m.Called(filename)
+	return called.Bool(0)
 }
@@ -0,0 +1,126 @@
+package travis
+
+import (
+	"strings"
+)
+
+// token is a word of a shell command and its byte offsets in the command
+type token struct {
+	value string
+	start int
+	end   int
+}
+
+// span is the byte range of an image reference in a shell command
+type span struct {
+	start int
+	end   int
+}
+
+// booleanFlags of docker pull and docker run, all other flags take a value
+var booleanFlags = map[string]struct{}{
+	"--all-tags":              {},
+	"--detach":                {},
+	"--disable-content-trust": {},
+	"--init":                  {},
+	"--interactive":           {},
+	"--no-healthcheck":        {},
+	"--oom-kill-disable":      {},
+	"--privileged":            {},
+	"--publish-all":           {},
+	"--quiet":                 {},
+	"--read-only":             {},
+	"--rm":                    {},
+	"--tty":                   {},
+}
+
+// booleanShortFlags of the docker sub commands followed by options and the image.
+// They can be combined, e.g. -it or -dit. -a is --all-tags of pull, but --attach with a value for run.
+var booleanShortFlags = map[string]string{
+	"pull": "aq",
+	"run":  "diPt",
+}
+
+// tokenize splits command at whitespace
+func tokenize(command string) []token {
+	tokens := make([]token, 0)
+	start := -1
+	for i, c := range command {
+		if c == ' ' || c == '\t' {
+			if start >= 0 {
+				tokens = append(tokens, token{value: command[start:i], start: start, end: i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{value: command[start:], start: start, end: len(command)})
+	}
+	return tokens
+}
+
+func isSeparator(value string) bool {
+	switch value {
+	case "&&", "||", ";", "|", "&":
+		return true
+	}
+	return false
+}
+
+// takesValue returns true when the flag of the docker sub command is followed by a separate value
+func takesValue(subCommand string, flag string) bool {
+	if strings.HasPrefix(flag, "--") {
+		if strings.Contains(flag, "=") {
+			return false
+		}
+		_, ok := booleanFlags[flag]
+		return !ok
+	}
+
+	// combined boolean flags (-it) or a flag with an attached value (-p80:80)
+	if strings.Trim(flag[1:], booleanShortFlags[subCommand]) == "" || len(flag) > 2 {
+		return false
+	}
+	return true
+}
+
+// dockerImages returns the spans of the images in docker pull and docker run commands of a shell command line
+func dockerImages(command string) []span {
+	spans := make([]span, 0)
+	tokens := tokenize(command)
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].value != "docker" {
+			continue
+		}
+		subCommand := tokens[i+1].value
+		if _, ok := booleanShortFlags[subCommand]; !ok {
+			continue
+		}
+
+		for j := i + 2; j < len(tokens); j++ {
+			t := tokens[j]
+			if isSeparator(t.value) {
+				break
+			}
+			if strings.HasPrefix(t.value, "-") {
+				if takesValue(subCommand, t.value) {
+					j++
+				}
+				continue
+			}
+
+			end := t.end
+			if strings.HasSuffix(t.value, ";") {
+				end--
+			}
+			if !strings.ContainsAny(command[t.start:end], `"'`) && end > t.start {
+				spans = append(spans, span{start: t.start, end: end})
+			}
+			i = j
+			break
+		}
+	}
+	return spans
+}
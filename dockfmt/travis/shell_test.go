@@ -0,0 +1,48 @@
+package travis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func images(command string) []string {
+	result := make([]string, 0)
+	for _, s := range dockerImages(command) {
+		result = append(result, command[s.start:s.end])
+	}
+	return result
+}
+
+func TestDockerImagesFindsPullAndRun(t *testing.T) {
+	assert.Equal(t, []string{"nginx:1.15"}, images("docker pull nginx:1.15"))
+	assert.Equal(t, []string{"nginx"}, images("docker run nginx"))
+	assert.Equal(t, []string{"alpine"}, images("sudo docker run alpine echo docker pull"))
+}
+
+func TestDockerImagesSkipsOptions(t *testing.T) {
+	assert.Equal(t, []string{"nginx"}, images("docker run -d -p 80:80 --name web nginx"))
+	assert.Equal(t, []string{"nginx"}, images("docker run -it --rm -e A=b -v/tmp:/tmp --network=host nginx sh"))
+	assert.Equal(t, []string{"nginx"}, images("docker pull -q --platform linux/amd64 nginx"))
+}
+
+func TestDockerImagesSkipsTheValueOfAttach(t *testing.T) {
+	assert.Equal(t, []string{"nginx"}, images("docker run -a stdout nginx"))
+	assert.Equal(t, []string{"nginx"}, images("docker run -a stdout -a stderr --rm nginx"))
+	assert.Equal(t, []string{"nginx"}, images("docker run -it -a stdin nginx"))
+	assert.Equal(t, []string{"nginx"}, images("docker pull -a nginx"))
+}
+
+func TestDockerImagesFindsEveryCommand(t *testing.T) {
+	assert.Equal(t, []string{"postgres:11", "nginx"}, images("docker pull postgres:11 && docker run -d nginx"))
+	assert.Equal(t, []string{"postgres:11", "nginx"}, images("docker pull postgres:11; docker run -d nginx"))
+}
+
+func TestDockerImagesIgnoresOtherCommands(t *testing.T) {
+	assert.Empty(t, images("docker build -t image ."))
+	assert.Empty(t, images("docker push image"))
+	assert.Empty(t, images("echo docker"))
+	assert.Empty(t, images("docker run -d"))
+	assert.Empty(t, images("docker run -d | grep nginx"))
+	assert.Empty(t, images(`docker run "nginx"`))
+}
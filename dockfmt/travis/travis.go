@@ -0,0 +1,151 @@
+package travis
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*travisFormat)(nil)
+
+// phases are the keys containing shell commands in the order they are executed
+var phases = []string{
+	"before_install",
+	"install",
+	"before_script",
+	"script",
+	"before_cache",
+	"after_success",
+	"after_failure",
+	"before_deploy",
+	"after_deploy",
+	"after_script",
+}
+
+// travisKeys are top level keys of which at least one is used by every .travis.yml
+var travisKeys = []string{"language", "os", "dist"}
+
+type travisFormat struct {
+}
+
+func (format *travisFormat) Name() string {
+	return "travis-ci"
+}
+
+func New() dockfmt.Format {
+	return newTravisFormat()
+}
+
+func newTravisFormat() *travisFormat {
+	return new(travisFormat)
+}
+
+func (format *travisFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) (dockfmt.Document, error) {
+	document, err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return nil, dockfmt.FormatErrorNew(err)
+	}
+	return document, nil
+}
+
+func (format *travisFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) (*yamlfmt.Document, error) {
+	content, root, err := yamlfmt.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Top level element is not a mapping")
+	}
+
+	// other CI configurations also use keys like script or before_script
+	found := false
+	for _, key := range travisKeys {
+		if yamlfmt.MappingValue(root, key) != nil {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("Neither language, os nor dist found")
+	}
+
+	return yamlfmt.DocumentNew(content, imageNodes(log, root)), nil
+}
+
+// imageNodes returns the images of docker commands in the phases of the build and of the jobs included in the build matrix
+func imageNodes(log logrus.FieldLogger, root *yaml.Node) []yamlfmt.Image {
+	images := phaseImages(log, "", root)
+
+	for _, matrix := range []string{"jobs", "matrix"} {
+		include := yamlfmt.ResolveAlias(yamlfmt.MappingValue(yamlfmt.MappingValue(root, matrix), "include"))
+		if include == nil || include.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, job := range include.Content {
+			images = append(images, phaseImages(log, matrix+".include["+strconv.Itoa(i)+"].", job)...)
+		}
+	}
+
+	return images
+}
+
+// phaseImages returns the images in the commands of all phases, a phase is either a single command or a list of commands
+func phaseImages(log logrus.FieldLogger, prefix string, job *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	for _, phase := range phases {
+		commands := yamlfmt.ResolveAlias(yamlfmt.MappingValue(job, phase))
+		if commands == nil {
+			continue
+		}
+
+		if commands.Kind == yaml.SequenceNode {
+			for i, command := range commands.Content {
+				images = append(images, commandImages(log, prefix+phase+"["+strconv.Itoa(i)+"]", command)...)
+			}
+		} else {
+			images = append(images, commandImages(log, prefix+phase, commands)...)
+		}
+	}
+	return images
+}
+
+// commandImages returns the images of docker pull and docker run in a command.
+// Multi line commands (literal and folded style) are not supported, they are skipped with a warning when they contain images.
+func commandImages(log logrus.FieldLogger, instruction string, command *yaml.Node) []yamlfmt.Image {
+	command = yamlfmt.ResolveAlias(command)
+	if command.Kind != yaml.ScalarNode {
+		return nil
+	}
+
+	if command.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		for _, line := range strings.Split(command.Value, "\n") {
+			if len(dockerImages(line)) > 0 {
+				log.Warnf("Skipping images of %s in line %d, multi line commands are not supported", instruction, command.Line)
+				break
+			}
+		}
+		return nil
+	}
+
+	images := make([]yamlfmt.Image, 0)
+	for _, s := range dockerImages(command.Value) {
+		images = append(images, yamlfmt.Image{
+			Node:        command,
+			Prefix:      command.Value[:s.start],
+			Suffix:      command.Value[s.end:],
+			Instruction: instruction,
+		})
+	}
+	return images
+}
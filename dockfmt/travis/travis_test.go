@@ -0,0 +1,190 @@
+package travis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockfmttst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestTravisName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "travis-ci", name)
+}
+
+func TestTravisFormatEmptyIsInvalid(t *testing.T) {
+	file := ``
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestTravisFormatGitlabIsInvalid(t *testing.T) {
+	file := `before_script:
+  - docker pull nginx
+build:
+  script: make`
+	format := New()
+	_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Error(t, valid)
+}
+
+func TestTravisFormatIsValid(t *testing.T) {
+	files := map[string]string{
+		"language": `language: go`,
+		"os":       `os: linux`,
+		"dist":     `dist: xenial`,
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			_, valid := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Nil(t, valid)
+		})
+	}
+}
+
+func TestTravisCallsProcessorForEveryImage(t *testing.T) {
+	file := `language: go
+services:
+  - docker
+before_install:
+  - docker pull postgres:11
+  - docker run -d -p 5432:5432 postgres:11
+script: docker run --rm golang:1.12 go test ./...
+jobs:
+  include:
+    - stage: deploy
+      script:
+        - docker pull alpine:3.9 && docker run alpine:3.9 echo deploy
+`
+
+	images := make([]string, 0)
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"postgres:11", "postgres:11", "golang:1.12", "alpine:3.9", "alpine:3.9"}, images)
+}
+
+func TestTravisReportsLocations(t *testing.T) {
+	file := `language: go
+script:
+  - docker pull nginx && docker run -d nginx
+after_script: "docker run alpine"`
+
+	locations := make([]dockfmt.Location, 0)
+	_, err := dockfmttst.ProcessLocated(t, New(), log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{Line: 3, Column: 17, Instruction: "script[0]"},
+		{Line: 3, Column: 40, Instruction: "script[0]"},
+		{Line: 4, Column: 27, Instruction: "after_script"},
+	}, locations)
+}
+
+func TestTravisUnchangedReferencesKeepFileIdentical(t *testing.T) {
+	file := `# a comment
+language: go
+script:
+  - docker run nginx   # trailing comment
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, file, out)
+}
+
+func TestTravisRewritesImagesInPlace(t *testing.T) {
+	file := `# a comment
+language: go
+before_install:
+  - docker pull nginx && docker run -d --name web nginx   # trailing comment
+script:
+  - 'docker run --rm golang:1.12 go test ./...'
+  - docker build -t image .
+`
+	expected := `# a comment
+language: go
+before_install:
+  - docker pull nginx:pinned && docker run -d --name web nginx:pinned   # trailing comment
+script:
+  - 'docker run --rm golang:pinned go test ./...'
+  - docker build -t image .
+`
+	out, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, out)
+}
+
+func TestTravisSkipsVariablesAndMultiLineCommands(t *testing.T) {
+	file := `language: go
+script:
+  - docker run $IMAGE
+  - |
+    echo start
+    docker run nginx
+  - >
+    docker pull
+    redis
+  - |
+    echo no images
+  - docker run alpine`
+
+	output := bytes.NewBuffer(nil)
+	logger := logrus.New()
+	logger.SetOutput(output)
+
+	format := New()
+	document, err := format.ValidateInput(logger, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	images := make([]string, 0)
+	err = document.Process(logger, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine"}, images)
+	assert.Contains(t, output.String(), "Skipping images of script[1] in line 4, multi line commands are not supported")
+	assert.Contains(t, output.String(), "Skipping images of script[2] in line 7, multi line commands are not supported")
+	assert.NotContains(t, output.String(), "script[3]")
+}
+
+func TestTravisPassProcessorErrors(t *testing.T) {
+	file := `language: go
+script: docker run nginx`
+
+	expected := errors.New("expected")
+	_, err := dockfmttst.Process(t, New(), log, file, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, expected
+	})
+
+	assert.Equal(t, dockfmt.FormatErrorNew(expected), err)
+}
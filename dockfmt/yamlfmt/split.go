@@ -0,0 +1,200 @@
+package yamlfmt
+
+import (
+	"strings"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// SplitImage is an image reference split into several scalars of a mapping, e.g. repository and tag.
+// Only Name is required, Domain is used for layouts with a separate registry key.
+type SplitImage struct {
+	Mapping     *yaml.Node
+	Domain      *yaml.Node
+	Name        *yaml.Node
+	Tag         *yaml.Node
+	Digest      *yaml.Node
+	DigestKey   string
+	Instruction string
+}
+
+// WithSplitImages adds the images that are split into several keys of a mapping.
+// Images are ignored when Mapping is not a mapping or Name is not a scalar,
+// mappings and names reached more than once via aliases and merge keys are only processed once.
+func (document *Document) WithSplitImages(images []SplitImage) *Document {
+	seen := make(map[*yaml.Node]struct{})
+	for _, image := range images {
+		image.Mapping = ResolveAlias(image.Mapping)
+		image.Domain = ResolveAlias(image.Domain)
+		image.Name = ResolveAlias(image.Name)
+		image.Tag = ResolveAlias(image.Tag)
+		image.Digest = ResolveAlias(image.Digest)
+		if image.Mapping == nil || image.Mapping.Kind != yaml.MappingNode || !isScalar(image.Name) {
+			continue
+		}
+		_, seenMapping := seen[image.Mapping]
+		_, seenName := seen[image.Name]
+		if seenMapping || seenName {
+			continue
+		}
+		seen[image.Mapping] = struct{}{}
+		seen[image.Name] = struct{}{}
+		document.splitImages = append(document.splitImages, image)
+	}
+	return document
+}
+
+func isScalar(node *yaml.Node) bool {
+	return node != nil && node.Kind == yaml.ScalarNode
+}
+
+func scalarValue(node *yaml.Node) string {
+	if !isScalar(node) {
+		return ""
+	}
+	return node.Value
+}
+
+// String returns the reference assembled from the parts, e.g. registry/repository:tag@digest
+func (image SplitImage) String() string {
+	s := scalarValue(image.Name)
+	if domain := scalarValue(image.Domain); domain != "" {
+		s = domain + "/" + s
+	}
+	if tag := scalarValue(image.Tag); tag != "" {
+		s += ":" + tag
+	}
+	if digest := scalarValue(image.Digest); digest != "" {
+		s += "@" + digest
+	}
+	return s
+}
+
+// process calls imageNameProcessor with the assembled reference and writes the changed parts back.
+// Names are only written when they changed, tags only when the processed reference has a tag.
+// Missing digests are added with DigestKey after the last of the other keys.
+// Images with an empty tag and no digest are skipped, they are not latest, e.g. helm charts default them to the app version.
+// Images in flow mappings are skipped when a digest would have to be added.
+func (image SplitImage) process(log logrus.FieldLogger, lines []string, edits *edits, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	value := image.String()
+	if isSubstituted(log, value) {
+		return nil
+	}
+
+	if isScalar(image.Tag) && image.Tag.Value == "" && scalarValue(image.Digest) == "" {
+		log.Warnf("Skipping image %s in line %d, the tag is empty", value, image.Name.Line)
+		return nil
+	}
+
+	log.Infof("Found image %s", value)
+	ref, err := dockref.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	processed, err := imageNameProcessor(ref, dockfmt.Location{
+		Line:        image.Name.Line,
+		Column:      valueColumn(lines, image.Name),
+		Instruction: image.Instruction,
+	})
+	if err != nil {
+		return err
+	}
+
+	formatted := processed.String()
+	if formatted == value {
+		return nil
+	}
+
+	format := processed.Format()
+	if format&dockref.FormatHasName == 0 {
+		return errors.Errorf("Image %s in line %d requires a name", value, image.Name.Line)
+	}
+
+	digest := ""
+	if format&dockref.FormatHasDigest != 0 {
+		digest = processed.DigestString()
+	}
+
+	if digest != "" && !isScalar(image.Digest) && image.Mapping.Style&yaml.FlowStyle != 0 {
+		log.Warnf("Skipping image %s in line %d, cannot add %s to the flow mapping", value, image.Mapping.Line, image.DigestKey)
+		return nil
+	}
+
+	log.Infof("Pinning '%s' as '%s'", value, formatted)
+
+	if processed.Name() != ref.Name() {
+		if isScalar(image.Domain) {
+			image.replace(edits, image.Domain, processed.Domain())
+			image.replace(edits, image.Name, processed.Path())
+		} else {
+			image.replace(edits, image.Name, nameOf(formatted, processed))
+		}
+	}
+
+	if format&dockref.FormatHasTag != 0 && isScalar(image.Tag) {
+		image.replace(edits, image.Tag, processed.Tag())
+	}
+
+	if isScalar(image.Digest) {
+		image.replace(edits, image.Digest, digest)
+	} else if digest != "" {
+		return image.insertDigest(lines, edits, digest)
+	}
+
+	return nil
+}
+
+func (image SplitImage) replace(edits *edits, node *yaml.Node, value string) {
+	if node.Value != value {
+		edits.replace(node, 0, len(node.Value), value)
+	}
+}
+
+// nameOf returns the name part of formatted, which is the formatted reference without tag and digest
+func nameOf(formatted string, ref dockref.Reference) string {
+	if i := strings.Index(formatted, "@"); i >= 0 {
+		formatted = formatted[:i]
+	}
+	return strings.TrimSuffix(formatted, ":"+ref.Tag())
+}
+
+// insertDigest adds the digest key after the last line of the other keys, using the indentation of the name's key
+func (image SplitImage) insertDigest(lines []string, edits *edits, digest string) error {
+	var nameKey *yaml.Node
+	last := 0
+	for i := 1; i < len(image.Mapping.Content); i += 2 {
+		key := image.Mapping.Content[i-1]
+		switch ResolveAlias(image.Mapping.Content[i]) {
+		case image.Name:
+			nameKey = key
+		case image.Domain, image.Tag:
+		default:
+			continue
+		}
+		if key.Line > last {
+			last = key.Line
+		}
+	}
+
+	if nameKey == nil || last < 1 || last > len(lines) {
+		return errors.Errorf("Cannot add %s to the mapping in line %d", image.DigestKey, image.Mapping.Line)
+	}
+
+	line := lines[nameKey.Line-1]
+	indentation := line[:columnOffset(line, nameKey.Column)]
+	// the first key of a mapping in a sequence starts after "- "
+	indentation = strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, indentation)
+
+	edits.insertAfter(last, indentation+image.DigestKey+": "+digest+"\n")
+	return nil
+}
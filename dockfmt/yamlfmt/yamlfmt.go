@@ -0,0 +1,449 @@
+// Package yamlfmt contains the parts shared by formats based on YAML.
+// Image references are rewritten in the original content, so comments, anchors and the order of keys are preserved.
+package yamlfmt
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// ensure Document is implemented
+var _ dockfmt.Document = (*Document)(nil)
+
+// Image is a scalar node containing an image reference.
+// The value of the node is Prefix followed by the image reference and Suffix, e.g. docker://nginx
+type Image struct {
+	Node        *yaml.Node
+	Prefix      string
+	Suffix      string
+	Instruction string
+}
+
+func (image Image) start() int {
+	return len(image.Prefix)
+}
+
+func (image Image) end() int {
+	return len(image.Node.Value) - len(image.Suffix)
+}
+
+// replacement of the image reference between start and end of a node's value
+type replacement struct {
+	start int
+	end   int
+	value string
+}
+
+// Document rewrites the image references of a parsed YAML file
+type Document struct {
+	content     []byte
+	images      []Image
+	splitImages []SplitImage
+}
+
+// Parse reads the YAML content of reader and returns the content and the top level node of the first document
+func Parse(reader io.Reader) ([]byte, *yaml.Node, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, nil, errors.Errorf("No YAML document found")
+	}
+
+	return content, document.Content[0], nil
+}
+
+// ParseAll reads the YAML content of reader and returns the content and the top level nodes of all
+// documents separated by ---, empty documents are skipped
+func ParseAll(reader io.Reader) ([]byte, []*yaml.Node, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roots := make([]*yaml.Node, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		err = decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(document.Content) == 0 || document.Content[0].Tag == "!!null" {
+			continue
+		}
+		roots = append(roots, document.Content[0])
+	}
+
+	if len(roots) == 0 {
+		return nil, nil, errors.Errorf("No YAML document found")
+	}
+
+	return content, roots, nil
+}
+
+// DocumentNew creates a Document for the images in content.
+// Images that are not scalars or don't match Prefix and Suffix are ignored,
+// nodes reached more than once via aliases are only processed once.
+func DocumentNew(content []byte, images []Image) *Document {
+	type key struct {
+		node  *yaml.Node
+		start int
+	}
+	seen := make(map[key]struct{})
+	unique := make([]Image, 0)
+	for _, image := range images {
+		node := ResolveAlias(image.Node)
+		if node == nil || node.Kind != yaml.ScalarNode ||
+			len(image.Prefix)+len(image.Suffix) >= len(node.Value) ||
+			!strings.HasPrefix(node.Value, image.Prefix) || !strings.HasSuffix(node.Value, image.Suffix) {
+			continue
+		}
+		image.Node = node
+		if _, ok := seen[key{node, image.start()}]; ok {
+			continue
+		}
+		seen[key{node, image.start()}] = struct{}{}
+		unique = append(unique, image)
+	}
+
+	return &Document{
+		content: content,
+		images:  unique,
+	}
+}
+
+func (document *Document) Process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	err := document.process(log, w, imageNameProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+	return nil
+}
+
+func (document *Document) process(log logrus.FieldLogger, w io.Writer, imageNameProcessor dockfmt.LocatedImageNameProcessor) error {
+	lines := splitLines(document.content)
+	edits := editsNew()
+
+	for _, image := range document.images {
+		node := image.Node
+		value := node.Value[image.start():image.end()]
+		if isSubstituted(log, value) {
+			continue
+		}
+
+		log.Infof("Found image %s", value)
+		ref, err := dockref.Parse(value)
+		if err != nil {
+			return err
+		}
+
+		processed, err := imageNameProcessor(ref, dockfmt.Location{
+			Line:        node.Line,
+			Column:      valueColumn(lines, node) + utf8.RuneCountInString(image.Prefix),
+			Instruction: image.Instruction,
+		})
+		if err != nil {
+			return err
+		}
+
+		formatted := processed.String()
+		if formatted == value {
+			continue
+		}
+
+		log.Infof("Pinning '%s' as '%s'", value, formatted)
+		edits.replace(node, image.start(), image.end(), formatted)
+	}
+
+	for _, image := range document.splitImages {
+		err := image.process(log, lines, edits, imageNameProcessor)
+		if err != nil {
+			return err
+		}
+	}
+
+	lines, err := edits.apply(lines)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isSubstituted returns true for $VARIABLE, ${{ expression }}, << parameters.name >> or {{ .Values.template }}
+func isSubstituted(log logrus.FieldLogger, value string) bool {
+	if strings.Contains(value, "$") || strings.Contains(value, "<<") || strings.Contains(value, "{{") {
+		log.Warnf("Skipping image %s, variable substitution is not supported", value)
+		return true
+	}
+	return false
+}
+
+// edits collects the changes to the lines of a document, they are applied after all images are processed
+type edits struct {
+	nodes        []*yaml.Node
+	replacements map[*yaml.Node][]replacement
+	insertions   map[int][]string
+}
+
+func editsNew() *edits {
+	return &edits{
+		nodes:        make([]*yaml.Node, 0),
+		replacements: make(map[*yaml.Node][]replacement),
+		insertions:   make(map[int][]string),
+	}
+}
+
+// replace replaces the value of node between start and end
+func (e *edits) replace(node *yaml.Node, start int, end int, value string) {
+	if _, ok := e.replacements[node]; !ok {
+		e.nodes = append(e.nodes, node)
+	}
+	e.replacements[node] = append(e.replacements[node], replacement{start: start, end: end, value: value})
+}
+
+// insertAfter inserts the line text after the 1-based line number
+func (e *edits) insertAfter(line int, text string) {
+	e.insertions[line] = append(e.insertions[line], text)
+}
+
+func (e *edits) apply(lines []string) ([]string, error) {
+	for _, node := range e.nodes {
+		err := replaceScalar(lines, node, replaceAll(node.Value, e.replacements[node]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(e.insertions) == 0 {
+		return lines, nil
+	}
+
+	result := make([]string, 0, len(lines))
+	for i, line := range lines {
+		inserted := e.insertions[i+1]
+		if len(inserted) > 0 && !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		result = append(result, line)
+		result = append(result, inserted...)
+	}
+	return result, nil
+}
+
+// replaceAll replaces the parts of value, starting with the last one so the offsets of the others stay valid
+func replaceAll(value string, replacements []replacement) string {
+	sort.Slice(replacements, func(i, j int) bool {
+		return replacements[i].start > replacements[j].start
+	})
+	for _, r := range replacements {
+		value = value[:r.start] + r.value + value[r.end:]
+	}
+	return value
+}
+
+// MappingValue returns the value for key in mapping, following merge keys ("<<").
+// Returns nil when the key does not exist or mapping is not a mapping.
+func MappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	mapping = ResolveAlias(mapping)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var merged []*yaml.Node
+	for i := 1; i < len(mapping.Content); i += 2 {
+		k := mapping.Content[i-1]
+		v := mapping.Content[i]
+		if k.Value == key {
+			return v
+		}
+		if k.Tag == "!!merge" {
+			merged = append(merged, v)
+		}
+	}
+
+	for _, m := range merged {
+		m = ResolveAlias(m)
+		if m.Kind == yaml.SequenceNode {
+			for _, s := range m.Content {
+				if v := MappingValue(s, key); v != nil {
+					return v
+				}
+			}
+		} else if v := MappingValue(m, key); v != nil {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// ResolveAlias returns the node an alias points to, other nodes are returned as they are
+func ResolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+func splitLines(content []byte) []string {
+	lines := make([]string, 0)
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			i = len(content) - 1
+		}
+		lines = append(lines, string(content[:i+1]))
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// replaceScalar replaces the single line scalar node in lines with value, keeping the quoting style.
+// The node's column points to the node's properties (anchor and tag), which are skipped.
+// Quoted scalars are located by decoding the quoted token, they can contain escapes like "nginx\u003a1.25".
+func replaceScalar(lines []string, node *yaml.Node, value string) error {
+	if node.Line < 1 || node.Line > len(lines) {
+		return errors.Errorf("Line %d of %s out of range", node.Line, node.Value)
+	}
+
+	line := lines[node.Line-1]
+	idx := skipProperties(line, columnOffset(line, node.Column))
+
+	var token, replacement string
+	switch node.Style &^ yaml.TaggedStyle {
+	case 0:
+		token = node.Value
+		replacement = value
+		if value == "" {
+			replacement = `""`
+		}
+	case yaml.DoubleQuotedStyle:
+		token = quotedToken(line[idx:], '"')
+		replacement = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	case yaml.SingleQuotedStyle:
+		token = quotedToken(line[idx:], '\'')
+		replacement = `'` + strings.Replace(value, `'`, `''`, -1) + `'`
+	default:
+		return errors.Errorf("Unsupported style for image %s in line %d", node.Value, node.Line)
+	}
+
+	if !strings.HasPrefix(line[idx:], token) || !isToken(token, node) {
+		return errors.Errorf("Could not find image %s in line %d", node.Value, node.Line)
+	}
+
+	lines[node.Line-1] = line[:idx] + replacement + line[idx+len(token):]
+	return nil
+}
+
+// quotedToken returns the scalar quoted with quote at the start of s including the quotes, empty when s does not
+// start with a quoted scalar. Double quoted scalars escape with a backslash, single quoted scalars with a double quote.
+func quotedToken(s string, quote byte) string {
+	if len(s) == 0 || s[0] != quote {
+		return ""
+	}
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == quote && i+1 < len(s) && s[i+1] == quote:
+			i++
+		case s[i] == quote:
+			return s[:i+1]
+		}
+	}
+	return ""
+}
+
+// isToken returns true when the token decodes to the value of node
+func isToken(token string, node *yaml.Node) bool {
+	if node.Style&^yaml.TaggedStyle == 0 {
+		return token == node.Value
+	}
+	var decoded string
+	if err := yaml.Unmarshal([]byte(token), &decoded); err != nil {
+		return false
+	}
+	return decoded == node.Value
+}
+
+// scalarColumn returns the 1-based column of the scalar node's value including quotes, after its anchor and tag
+func scalarColumn(lines []string, node *yaml.Node) int {
+	if node.Line < 1 || node.Line > len(lines) {
+		return node.Column
+	}
+	line := lines[node.Line-1]
+	idx := skipProperties(line, columnOffset(line, node.Column))
+	return utf8.RuneCountInString(line[:idx]) + 1
+}
+
+// ValueColumn returns the 1-based column of the value of the scalar node parsed from content,
+// after its anchor, tag and quote
+func ValueColumn(content []byte, node *yaml.Node) int {
+	return valueColumn(splitLines(content), node)
+}
+
+// valueColumn returns the 1-based column of the scalar node's value, after its anchor, tag and quote
+func valueColumn(lines []string, node *yaml.Node) int {
+	return scalarColumn(lines, node) + quoteLength(node)
+}
+
+func quoteLength(node *yaml.Node) int {
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		return 1
+	}
+	return 0
+}
+
+// skipProperties skips anchors (&anchor) and tags (!tag) starting at offset
+func skipProperties(line string, offset int) int {
+	for offset < len(line) && (line[offset] == '&' || line[offset] == '!') {
+		for offset < len(line) && line[offset] != ' ' && line[offset] != '\t' {
+			offset++
+		}
+		for offset < len(line) && (line[offset] == ' ' || line[offset] == '\t') {
+			offset++
+		}
+	}
+	return offset
+}
+
+// columnOffset converts the 1-based column (counted in characters) to a byte offset in line
+func columnOffset(line string, column int) int {
+	offset := 0
+	for c := 1; c < column && offset < len(line); c++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,125 @@
+package yamlfmt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestParseEmptyIsInvalid(t *testing.T) {
+	_, _, err := Parse(strings.NewReader(``))
+	assert.Error(t, err)
+}
+
+func TestParseReturnsTopLevelNode(t *testing.T) {
+	content, root, err := Parse(strings.NewReader(`key: value`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "key: value", string(content))
+	assert.Equal(t, yaml.MappingNode, root.Kind)
+}
+
+func TestDocumentIgnoresDuplicatesAndNonScalars(t *testing.T) {
+	content, root, _ := Parse(strings.NewReader(`a: &img nginx
+b: *img
+c: [nginx]
+`))
+
+	document := DocumentNew(content, []Image{
+		{Node: MappingValue(root, "a"), Instruction: "a"},
+		{Node: MappingValue(root, "b"), Instruction: "b"},
+		{Node: MappingValue(root, "c"), Instruction: "c"},
+		{Node: MappingValue(root, "missing"), Instruction: "missing"},
+	})
+
+	locations := make([]dockfmt.Location, 0)
+	buffer := bytes.NewBuffer(nil)
+	err := document.Process(log, buffer, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		locations = append(locations, location)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{{Line: 1, Column: 9, Instruction: "a"}}, locations)
+	assert.Equal(t, string(content), buffer.String())
+}
+
+func TestDocumentReportsUnsupportedStyles(t *testing.T) {
+	content, root, _ := Parse(strings.NewReader(`image: >
+  nginx
+`))
+
+	document := DocumentNew(content, []Image{{Node: MappingValue(root, "image"), Instruction: "image"}})
+
+	err := document.Process(log, bytes.NewBuffer(nil), func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Error(t, err)
+}
+
+func TestDocumentRewritesQuotedScalarsWithEscapes(t *testing.T) {
+	content, root, _ := Parse(strings.NewReader(`a: "nginx\u003a1.25" # "escaped"
+b: &anchor !!str "alpine\x3a3.18"
+c: 'my''repo/img:1'
+`))
+
+	document := DocumentNew(content, []Image{
+		{Node: MappingValue(root, "a"), Instruction: "a"},
+		{Node: MappingValue(root, "b"), Instruction: "b"},
+		{Node: MappingValue(root, "c"), Prefix: "my'", Instruction: "c"},
+	})
+
+	images := make([]string, 0)
+	buffer := bytes.NewBuffer(nil)
+	err := document.Process(log, buffer, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		images = append(images, r.Original())
+		return r.WithTag("pinned").WithRequestedFormat(dockref.FormatHasName | dockref.FormatHasTag)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx:1.25", "alpine:3.18", "repo/img:1"}, images)
+	assert.Equal(t, `a: "nginx:pinned" # "escaped"
+b: &anchor !!str "alpine:pinned"
+c: 'my''repo/img:pinned'
+`, buffer.String())
+}
+
+func TestQuotedToken(t *testing.T) {
+	assert.Equal(t, `"a\"b"`, quotedToken(`"a\"b" # "c"`, '"'))
+	assert.Equal(t, `'a''b'`, quotedToken(`'a''b' # 'c'`, '\''))
+	assert.Equal(t, ``, quotedToken(`"unterminated`, '"'))
+	assert.Equal(t, ``, quotedToken(`plain`, '"'))
+}
+
+func TestParseAllReturnsEveryDocument(t *testing.T) {
+	content, roots, err := ParseAll(strings.NewReader(`---
+a: 1
+---
+---
+b: 2
+`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "---\na: 1\n---\n---\nb: 2\n", string(content))
+	assert.Len(t, roots, 2)
+	assert.Equal(t, 2, roots[0].Line)
+	assert.Equal(t, 5, roots[1].Line)
+}
+
+func TestParseAllEmptyIsInvalid(t *testing.T) {
+	_, _, err := ParseAll(strings.NewReader("---\n---\n"))
+	assert.Error(t, err)
+}
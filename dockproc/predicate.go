@@ -4,15 +4,54 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/docker/distribution/reference"
 	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 type Predicate interface {
 	Matches(ref dockref.Reference) bool
 }
 
+// LocatedPredicate is a Predicate that also depends on the location of the image reference
+type LocatedPredicate interface {
+	Predicate
+	MatchesLocated(ref dockref.Reference, location dockfmt.Location) bool
+}
+
+// FailingPredicate is a Predicate that can fail to evaluate references, e.g. when it queries a resolver.
+// References that cannot be evaluated don't match, the errors are returned by Err.
+type FailingPredicate interface {
+	Predicate
+	// Err returns the errors of all references evaluated so far, nil when there was none
+	Err() error
+}
+
+// PredicateErr returns the errors of predicate and of the predicates it combines, nil when none failed
+func PredicateErr(predicate Predicate) error {
+	var result *multierror.Error
+	if failing, ok := predicate.(FailingPredicate); ok {
+		result = multierror.Append(result, failing.Err())
+	}
+	if and, ok := predicate.(AndPredicate); ok {
+		for _, p := range and.Predicates() {
+			result = multierror.Append(result, PredicateErr(p))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// MatchesLocated uses the location when predicate is a LocatedPredicate
+func MatchesLocated(predicate Predicate, ref dockref.Reference, location dockfmt.Location) bool {
+	if located, ok := predicate.(LocatedPredicate); ok {
+		return located.MatchesLocated(ref, location)
+	}
+	return predicate.Matches(ref)
+}
+
 var _ Predicate = (*anyPredicate)(nil)
 
 type anyPredicate struct {
@@ -61,18 +100,60 @@ func UnpinnedPredicateNew() (Predicate, error) {
 	return unpinnedPredicate{}, nil
 }
 
-//var _ Predicate = (*outdatedPredicate)(nil)
-//
-//type outdatedPredicate struct {
-//}
-//
-//func (outdatedPredicate) Matches(ref dockref.Reference) bool {
-//	panic("not implemented")
-//}
-//
-//func OutdatedPredicateNew() Predicate {
-//	return outdatedPredicate{}
-//}
+var _ FailingPredicate = (*outdatedPredicate)(nil)
+
+type outdatedPredicate struct {
+	resolver dockref.Resolver
+	log      logrus.FieldLogger
+	tags     map[string][]dockref.Reference
+	errors   *multierror.Error
+}
+
+// Matches returns true when the resolver knows a newer version with the same variant and precision.
+// References without version tag never match. Errors of the resolver are logged and don't match,
+// they are returned by Err, so commands fail instead of reporting the reference as up to date.
+func (p *outdatedPredicate) Matches(ref dockref.Reference) bool {
+	if !dockref.HasVersion(ref) {
+		return false
+	}
+
+	tags, ok := p.tags[ref.Name()]
+	if !ok {
+		var err error
+		tags, err = p.resolver.FindAllTags(ref)
+		if err != nil {
+			p.log.WithField("error", err.Error()).Errorf("Could not find tags of %s", ref.Original())
+			p.errors = multierror.Append(p.errors, errors.Wrapf(err, "Could not find tags of %s", ref.Original()))
+			return false
+		}
+		p.tags[ref.Name()] = tags
+	}
+
+	newest, err := dockref.NewestTag(ref, tags, dockref.UpdatePolicyMajor)
+	if err != nil {
+		p.log.WithField("error", err.Error()).Errorf("Could not find newest version of %s", ref.Original())
+		p.errors = multierror.Append(p.errors, errors.Wrapf(err, "Could not find newest version of %s", ref.Original()))
+		return false
+	}
+
+	return newest.Tag() != ref.Tag()
+}
+
+func (p *outdatedPredicate) Err() error {
+	return p.errors.ErrorOrNil()
+}
+
+func OutdatedPredicateNew(resolver dockref.Resolver, log logrus.FieldLogger) (Predicate, error) {
+	if resolver == nil {
+		return nil, errors.New("outdated predicate requires a resolver")
+	}
+
+	return &outdatedPredicate{
+		resolver: resolver,
+		log:      log,
+		tags:     make(map[string][]dockref.Reference),
+	}, nil
+}
 
 var _ Predicate = (*untaggedPredicate)(nil)
 
@@ -284,12 +365,47 @@ func DigestsPredicateNew(digests []string) (Predicate, error) {
 	return digestsPredicate{digests: digests}, nil
 }
 
+var _ LocatedPredicate = (*stagesPredicate)(nil)
+
+type stagesPredicate struct {
+	stages []string
+}
+
+// Matches returns false, the stage is part of the location
+func (p stagesPredicate) Matches(ref dockref.Reference) bool {
+	return false
+}
+
+func (p stagesPredicate) MatchesLocated(ref dockref.Reference, location dockfmt.Location) bool {
+	for _, stage := range p.stages {
+		if isRegex(stage) {
+			if regExpMatches(stage, location.Stage) {
+				return true
+			}
+		} else if strings.EqualFold(stage, location.Stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// StagesPredicateNew matches image references used in one of the build stages, stage names are case insensitive
+func StagesPredicateNew(stages []string) (Predicate, error) {
+	e := vaildateRegex(stages)
+	var predicate Predicate
+	if e == nil {
+		predicate = stagesPredicate{stages: stages}
+	}
+	return predicate, e
+}
+
 type AndPredicate interface {
 	Predicate
 	Predicates() []Predicate
 }
 
 var _ AndPredicate = (*andPredicate)(nil)
+var _ LocatedPredicate = (*andPredicate)(nil)
 
 type andPredicate struct {
 	predicates []Predicate
@@ -308,6 +424,15 @@ func (a andPredicate) Matches(ref dockref.Reference) bool {
 	return true
 }
 
+func (a andPredicate) MatchesLocated(ref dockref.Reference, location dockfmt.Location) bool {
+	for _, p := range a.predicates {
+		if !MatchesLocated(p, ref, location) {
+			return false
+		}
+	}
+	return true
+}
+
 func AndPredicateNew(predicates []Predicate) (Predicate, error) {
 	return andPredicate{predicates: predicates}, nil
 }
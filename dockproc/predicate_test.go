@@ -1,10 +1,16 @@
 package dockproc
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestAnyPredicate(t *testing.T) {
@@ -635,6 +641,47 @@ func TestAndPredicate_Matches(t *testing.T) {
 	})
 }
 
+func TestAndPredicate_MatchesLocated(t *testing.T) {
+	ref, _ := dockref.Parse("a")
+	stages, e := StagesPredicateNew([]string{"build"})
+	assert.Nil(t, e)
+
+	predicate, e := AndPredicateNew([]Predicate{mockPredicate{true}, stages})
+	assert.Nil(t, e)
+
+	assert.True(t, MatchesLocated(predicate, ref, dockfmt.Location{Stage: "build"}))
+	assert.False(t, MatchesLocated(predicate, ref, dockfmt.Location{Stage: "final"}))
+	assert.False(t, predicate.Matches(ref))
+}
+
+func TestStagesPredicate(t *testing.T) {
+	predicate, e := StagesPredicateNew([]string{"build", "/^test-/"})
+	assert.Nil(t, e)
+
+	ref, _ := dockref.Parse("nginx")
+
+	for _, stage := range []string{"build", "Build", "test-unit"} {
+		t.Run("Matches "+stage, func(t *testing.T) {
+			assert.True(t, MatchesLocated(predicate, ref, dockfmt.Location{Stage: stage}))
+		})
+	}
+
+	for _, stage := range []string{"", "builder", "final-test-"} {
+		t.Run("Does not match "+stage, func(t *testing.T) {
+			assert.False(t, MatchesLocated(predicate, ref, dockfmt.Location{Stage: stage}))
+		})
+	}
+
+	t.Run("Does not match without location", func(t *testing.T) {
+		assert.False(t, predicate.Matches(ref))
+	})
+}
+
+func TestStagesPredicateWithInvalidRegExp(t *testing.T) {
+	_, e := StagesPredicateNew([]string{"/[/"})
+	assert.Error(t, e)
+}
+
 func TestAndPredicate_Predicates(t *testing.T) {
 	p1 := mockPredicate{true}
 	p2 := mockPredicate{false}
@@ -682,3 +729,88 @@ func TestTagsPredicate_returnsErrorForInvalidRegex(t *testing.T) {
 	assert.Nil(t, predicateNew)
 	assert.Error(t, err)
 }
+
+func TestOutdatedPredicate(t *testing.T) {
+	resolver := dockreftst.MockResolverNew()
+	tags := []dockref.Reference{
+		dockref.MustParse("nginx:latest"),
+		dockref.MustParse("nginx:1.14"),
+		dockref.MustParse("nginx:1.14.2"),
+		dockref.MustParse("nginx:1.15"),
+		dockref.MustParse("nginx:1.15.6"),
+		dockref.MustParse("nginx:1.15.6-alpine"),
+	}
+	resolver.OnFindAllTags(mock.Anything).Return(tags, nil).Once()
+
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil))
+
+	predicate, e := OutdatedPredicateNew(resolver, log)
+	assert.Nil(t, e)
+
+	shouldMatches := []string{
+		"nginx:1.14",
+		"nginx:1.14.2",
+		"nginx:1.14.2@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240",
+		"nginx:1.14.2-alpine",
+	}
+
+	for _, original := range shouldMatches {
+		t.Run("Matches "+original, func(t *testing.T) {
+			ref, e := dockref.Parse(original)
+
+			assert.Nil(t, e)
+			assert.True(t, predicate.Matches(ref))
+		})
+	}
+
+	shouldNotMatches := []string{
+		"nginx",
+		"nginx:latest",
+		"nginx:1.15",
+		"nginx:1.15.6",
+		"nginx:1.15.6-alpine",
+		"nginx:1.15.6-perl",
+		"nginx@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240",
+	}
+
+	for _, original := range shouldNotMatches {
+		t.Run("Not matching "+original, func(t *testing.T) {
+			ref, e := dockref.Parse(original)
+
+			assert.Nil(t, e)
+			assert.False(t, predicate.Matches(ref))
+		})
+	}
+
+	// tags are only queried once per repository
+	resolver.AssertNumberOfCalls(t, "FindAllTags", 1)
+}
+
+func TestOutdatedPredicateResolverError(t *testing.T) {
+	resolver := dockreftst.MockResolverNew()
+	resolver.OnFindAllTags(mock.Anything).Return([]dockref.Reference(nil), errors.New("expected"))
+
+	log := logrus.New()
+	buffer := bytes.NewBuffer(nil)
+	log.SetOutput(buffer)
+
+	predicate, e := OutdatedPredicateNew(resolver, log)
+	assert.Nil(t, e)
+
+	assert.Nil(t, PredicateErr(predicate))
+	assert.False(t, predicate.Matches(dockref.MustParse("nginx:1.14")))
+	assert.Contains(t, buffer.String(), "level=error")
+
+	and, e := AndPredicateNew([]Predicate{anyPredicate{}, predicate})
+	assert.Nil(t, e)
+	err := PredicateErr(and)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "expected")
+}
+
+func TestOutdatedPredicateRequiresResolver(t *testing.T) {
+	predicate, e := OutdatedPredicateNew(nil, logrus.New())
+	assert.Error(t, e)
+	assert.Nil(t, predicate)
+}
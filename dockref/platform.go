@@ -0,0 +1,55 @@
+package dockref
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Platform is the operating system and architecture an image is built for, e.g. linux/arm64 or linux/arm/v7
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// ParsePlatform parses the os/arch[/variant] format of the --platform flag
+func ParsePlatform(platform string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(platform), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, errors.Errorf("Invalid platform '%s', expected os/arch[/variant]", platform)
+	}
+
+	p := Platform{OS: parts[0], Architecture: normalizeArchitecture(parts[1])}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+func normalizeArchitecture(architecture string) string {
+	switch architecture {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	}
+	return architecture
+}
+
+// Matches returns true when an image for os, architecture and variant can be used for the platform.
+// A platform without variant matches every variant.
+func (p Platform) Matches(os string, architecture string, variant string) bool {
+	if !strings.EqualFold(p.OS, os) || p.Architecture != normalizeArchitecture(strings.ToLower(architecture)) {
+		return false
+	}
+	return p.Variant == "" || strings.EqualFold(p.Variant, variant)
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
@@ -0,0 +1,45 @@
+package dockref
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlatform(t *testing.T) {
+	t.Run("os and architecture", func(t *testing.T) {
+		platform, err := ParsePlatform("linux/arm64")
+		assert.Nil(t, err)
+		assert.Equal(t, Platform{OS: "linux", Architecture: "arm64"}, platform)
+	})
+	t.Run("variant", func(t *testing.T) {
+		platform, err := ParsePlatform("linux/arm/v7")
+		assert.Nil(t, err)
+		assert.Equal(t, Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, platform)
+		assert.Equal(t, "linux/arm/v7", platform.String())
+	})
+	t.Run("normalizes architecture", func(t *testing.T) {
+		platform, err := ParsePlatform("Linux/x86_64")
+		assert.Nil(t, err)
+		assert.Equal(t, Platform{OS: "linux", Architecture: "amd64"}, platform)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, invalid := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/x"} {
+			_, err := ParsePlatform(invalid)
+			assert.Error(t, err, invalid)
+		}
+	})
+}
+
+func TestPlatformMatches(t *testing.T) {
+	arm64 := Platform{OS: "linux", Architecture: "arm64"}
+	assert.True(t, arm64.Matches("linux", "arm64", ""))
+	assert.True(t, arm64.Matches("linux", "arm64", "v8"))
+	assert.True(t, arm64.Matches("linux", "aarch64", ""))
+	assert.False(t, arm64.Matches("linux", "amd64", ""))
+	assert.False(t, arm64.Matches("windows", "arm64", ""))
+
+	armv7 := Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	assert.True(t, armv7.Matches("linux", "arm", "v7"))
+	assert.False(t, armv7.Matches("linux", "arm", "v6"))
+}
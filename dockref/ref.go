@@ -3,9 +3,14 @@ package dockref
 import (
 	_ "crypto/sha256" // side effect: register sha256
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/blang/semver"
 	"github.com/docker/distribution/reference"
 	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 type Reference interface {
@@ -115,6 +120,25 @@ func (format Format) hasDomain() bool {
 func (format Format) hasDigest() bool {
 	return format&FormatHasDigest != 0
 }
+
+// Names returns the names of the set flags in the order name, tag, domain, digest
+func (format Format) Names() []string {
+	names := make([]string, 0)
+	if format.hasName() {
+		names = append(names, "name")
+	}
+	if format.hasTag() {
+		names = append(names, "tag")
+	}
+	if format.hasDomain() {
+		names = append(names, "domain")
+	}
+	if format.hasDigest() {
+		names = append(names, "digest")
+	}
+	return names
+}
+
 func (format Format) Valid() (bool, error) {
 	f := format
 	f &= ^(FormatHasName | FormatHasTag | FormatHasDomain | FormatHasDigest)
@@ -273,301 +297,302 @@ func (r dockref) WithTag(tag string) Reference {
 	return cpy
 }
 
-//
-//func MatchingDomainNameAndVariant(ref Reference, refs []Reference, log *logrus.Logger) ([]Reference, error) {
-//	// name and domain must match
-//	sameName := make([]Reference, 0)
-//	for _, r := range refs {
-//		if ref.Name() == r.Name() {
-//			sameName = append(sameName, r)
-//		}
-//	}
-//
-//	_, refVariant := splitVersionAndVariant(ref.Tag())
-//	sameVariant := make([]Reference, 0)
-//	for _, r := range sameName {
-//		_, rVariant := splitVersionAndVariant(r.Tag())
-//
-//		if refVariant == rVariant {
-//			sameVariant = append(sameVariant, r)
-//		}
-//	}
-//
-//	return sameVariant, nil
-//}
-//
-//func TagVersionsGreaterOrEqualOrNotAVersion(ref Reference, refs []Reference, log *logrus.Logger) ([]Reference, error) {
-//	refVersion, _, _, e := parseVeryTolerant(ref.Tag())
-//	if e != nil {
-//		return refs, nil
-//	}
-//
-//	greater := make([]Reference, 0)
-//	for _, r := range refs {
-//		tag := r.Tag()
-//		version, _, _, e := parseVeryTolerant(tag)
-//		if e != nil || semver.Version.GTE(version, refVersion) {
-//			greater = append(greater, r)
-//		}
-//	}
-//	return greater, nil
-//}
-//
-//func TagVersionsEqualOrNotAVersion(ref Reference, refs []Reference, log *logrus.Logger) ([]Reference, error) {
-//	refVersion, _, _, e := parseVeryTolerant(ref.Tag())
-//	if e != nil {
-//		return refs, nil
-//	}
-//
-//	greater := make([]Reference, 0)
-//	for _, r := range refs {
-//		tag := r.Tag()
-//		version, _, _, e := parseVeryTolerant(tag)
-//		if e != nil || semver.Version.EQ(version, refVersion) {
-//			greater = append(greater, r)
-//		}
-//	}
-//	return greater, nil
-//}
-
-//func MostPreciseTag(ref Reference, refs []Reference, resolver func(Reference) (Reference, error), log *logrus.Logger) (Reference, error) {
-//	if refs == nil {
-//		return nil, errors.New("refs is nil")
-//	}
-//	dig := ref.Digest()
-//
-//	seen := make(map[string]struct{}, len(refs))
-//	unique := make([]Reference, 0)
-//	for _, r := range refs {
-//		if r == nil {
-//			return nil, errors.New("refs contains nil element")
-//		}
-//
-//		tag := r.Tag()
-//		if _, ok := seen[tag]; !ok {
-//			seen[tag] = struct{}{}
-//			unique = append(unique, r)
-//		}
-//	}
-//	refs = unique
-//
-//	if len(refs) == 1 {
-//		return refs[0], nil
-//	}
-//
-//	nonSemVer, semvers := orderedSemVers(refs)
-//
-//	for _, sv := range semvers {
-//		sv, err := resolver(sv)
-//		if err != nil {
-//			return nil, err
-//		}
-//
-//		if dig == sv.Digest() {
-//			return sv, nil
-//		}
-//	}
-//
-//	// look for best non semver
-//
-//	// remove empty tag
-//	nonEmpty := removeEmpty(nonSemVer)
-//	if len(nonEmpty) == 1 {
-//		for _, r := range nonEmpty {
-//			sv, err := resolver(r)
-//			if err != nil {
-//				return nil, err
-//			}
-//
-//			if dig == sv.Digest() {
-//				return sv, nil
-//			}
-//		}
-//	}
-//
-//	nonLatest := removeLatest(nonEmpty)
-//	if len(nonLatest) == 1 {
-//		for _, r := range nonLatest {
-//			sv, err := resolver(r)
-//			if err != nil {
-//				return nil, err
-//			}
-//
-//			if dig == sv.Digest() {
-//				return sv, nil
-//			}
-//		}
-//	}
-//
-//	if log != nil {
-//		log.Warn("Didn't find semantic versioning tags, still trying to choose best tag but your mileage might vary")
-//	}
-//
-//	// take longest
-//
-//	longest := filterNonLongest(nonLatest)
-//
-//	// alphabetic
-//	sort.Slice(longest, func(i, j int) bool {
-//		a := longest[i]
-//		b := longest[j]
-//		return strings.Compare(a.Tag(), b.Tag()) > 0
-//	})
-//
-//	for _, r := range longest {
-//		sv, err := resolver(r)
-//		if err != nil {
-//			return nil, err
-//		}
-//
-//		if dig == sv.Digest() {
-//			return sv, nil
-//		}
-//	}
-//
-//	ref, e := ref.WithRequestedFormat(FormatFull)
-//	if e != nil {
-//		return nil, e
-//	}
-//
-//	return nil, errors.Errorf("Couldn't find the most precise tag for %s", ref.Formatted())
-//}
-//
-//func filterNonLongest(nonLatest []Reference) []Reference {
-//	sort.Slice(nonLatest, func(i, j int) bool {
-//		a := nonLatest[i]
-//		b := nonLatest[j]
-//
-//		return len(a.Tag()) > len(b.Tag())
-//	})
-//	maxLen := len(nonLatest[0].Tag())
-//	longest := make([]Reference, 0)
-//	for _, r := range nonLatest {
-//		if len(r.Tag()) == maxLen {
-//			longest = append(longest, r)
-//		}
-//	}
-//	return longest
-//}
-//
-//func removeLatest(refs []Reference) []Reference {
-//	nonLatest := make([]Reference, 0)
-//	for _, r := range refs {
-//		if r.Tag() == "latest" {
-//			continue
-//		}
-//		nonLatest = append(nonLatest, r)
-//	}
-//	return nonLatest
-//}
-//
-//func removeEmpty(refs []Reference) []Reference {
-//	nonEmpty := make([]Reference, 0)
-//	for _, r := range refs {
-//		if r.Tag() == "" {
-//			continue
-//		}
-//		nonEmpty = append(nonEmpty, r)
-//	}
-//	return nonEmpty
-//}
-//
-//func orderedSemVers(refs []Reference) ([]Reference, []Reference) {
-//
-//	type SemVer struct {
-//		ref       Reference
-//		precision int
-//		version   semver.Version
-//	}
-//
-//	nonSemVer := make([]Reference, 0)
-//	semvers := make([]SemVer, 0)
-//
-//	// look for semvers first, semvers wins
-//	for _, r := range refs {
-//		tag := r.Tag()
-//		version, versionPrecision, _, e := parseVeryTolerant(tag)
-//		if e != nil {
-//			nonSemVer = append(nonSemVer, r)
-//			continue
-//		}
-//		semvers = append(semvers, SemVer{r, versionPrecision, version})
-//	}
-//
-//	sort.Slice(semvers, func(i, j int) bool {
-//		a := semvers[i]
-//		b := semvers[j]
-//
-//		if a.version.EQ(b.version) {
-//			return a.precision > b.precision
-//		}
-//		return a.version.GT(b.version)
-//	})
-//
-//	semrefs := make([]Reference, 0)
-//	for _, sv := range semvers {
-//		semrefs = append(semrefs, sv.ref)
-//	}
-//
-//	return nonSemVer, semrefs
-//}
-
-//func splitVersionAndVariant(tag string) (version string, variant string) {
-//	lastIndex := strings.Index(tag, "-")
-//	if lastIndex >= 0 {
-//		version = tag[0:lastIndex]
-//		variant = tag[lastIndex+1:]
-//		_, e := semver.ParseTolerant(version)
-//		if e != nil {
-//			version = ""
-//			variant = tag
-//			return
-//		}
-//	} else {
-//		// no variant
-//		_, e := semver.ParseTolerant(tag)
-//		if e != nil {
-//			if tag == "latest" || tag == "" {
-//				version = tag
-//				variant = ""
-//			} else {
-//				version = ""
-//				variant = tag
-//			}
-//			return
-//		}
-//		version = tag
-//		variant = ""
-//		return
-//	}
-//
-//	return
-//}
-//
-//func parseVeryTolerant(tag string) (semver.Version, int, int, error) {
-//	version, variant := splitVersionAndVariant(tag)
-//
-//	parsed, e := semver.ParseTolerant(version)
-//	if e != nil {
-//		return parsed, 0, 0, e
-//	}
-//	if variant != "" {
-//		str := parsed.String()
-//		version = str + "-" + variant
-//	}
-//
-//	parsed, e = semver.ParseTolerant(version)
-//	if e != nil {
-//		return parsed, 0, 0, e
-//	}
-//	components := versionPrecision(version)
-//
-//	return parsed, components, 0, e
-//}
-//
-//func versionPrecision(version string) int {
-//	return len(strings.Split(version, "."))
-//}
+// MatchingDomainNameAndVariant returns the references from refs with the same name (including the domain)
+// and the same variant as ref, e.g. "alpine" for "nginx:1.15-alpine".
+// Untagged and latest references also match unversioned tags like "edge".
+func MatchingDomainNameAndVariant(ref Reference, refs []Reference) []Reference {
+	refVersion, refVariant := splitVersionAndVariant(ref.Tag())
+	matchUnversioned := refVariant == "" && (refVersion == "" || refVersion == "latest")
+
+	matching := make([]Reference, 0)
+	for _, r := range refs {
+		if ref.Name() != r.Name() {
+			continue
+		}
+
+		rVersion, rVariant := splitVersionAndVariant(r.Tag())
+		if refVariant == rVariant || (matchUnversioned && rVersion == "") {
+			matching = append(matching, r)
+		}
+	}
+
+	return matching
+}
+
+// TagVersionsWithinVersion returns the references from refs with a version tag that is at least as precise
+// as the version of ref and within the same version, e.g. "1.15" and "1.15.6" for "1". When ref is not
+// tagged with a version, refs is returned as is.
+func TagVersionsWithinVersion(ref Reference, refs []Reference) []Reference {
+	refVersion, _ := splitVersionAndVariant(ref.Tag())
+	if _, _, e := parseVeryTolerant(ref.Tag()); e != nil {
+		return refs
+	}
+	refComponents := versionComponents(refVersion)
+
+	within := make([]Reference, 0)
+	for _, r := range refs {
+		version, _ := splitVersionAndVariant(r.Tag())
+		if _, _, e := parseVeryTolerant(r.Tag()); e != nil {
+			continue
+		}
+
+		components := versionComponents(version)
+		if len(components) < len(refComponents) {
+			continue
+		}
+
+		isWithin := true
+		for i, c := range refComponents {
+			if components[i] != c {
+				isWithin = false
+				break
+			}
+		}
+
+		if isWithin {
+			within = append(within, r)
+		}
+	}
+
+	return within
+}
+
+// MostPreciseTag finds the reference with the most precise tag in refs that references the same image as ref.
+// ref must contain a digest, the digests of refs are resolved using resolver when they are missing.
+func MostPreciseTag(ref Reference, refs []Reference, resolver func(Reference) (Reference, error), log logrus.FieldLogger) (Reference, error) {
+	if refs == nil {
+		return nil, errors.New("refs is nil")
+	}
+	dig := ref.Digest()
+	if dig == "" {
+		return nil, errors.Errorf("Reference %s has no digest", ref.Original())
+	}
+
+	seen := make(map[string]struct{}, len(refs))
+	unique := make([]Reference, 0)
+	for _, r := range refs {
+		if r == nil {
+			return nil, errors.New("refs contains nil element")
+		}
+
+		tag := r.Tag()
+		if _, ok := seen[tag]; !ok {
+			seen[tag] = struct{}{}
+			unique = append(unique, r)
+		}
+	}
+
+	refs = MatchingDomainNameAndVariant(ref, unique)
+	refs = TagVersionsWithinVersion(ref, refs)
+
+	sameDigest := func(r Reference) (Reference, bool, error) {
+		if r.DigestString() == "" {
+			resolved, err := resolver(r)
+			if err != nil {
+				return nil, false, err
+			}
+			r = resolved
+		}
+
+		return r, r.Digest() == dig, nil
+	}
+
+	firstWithSameDigest := func(candidates []Reference) (Reference, error) {
+		for _, r := range candidates {
+			r, same, err := sameDigest(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if same {
+				return r, nil
+			}
+		}
+		return nil, nil
+	}
+
+	nonSemVer, semvers := orderedSemVers(refs)
+
+	found, err := firstWithSameDigest(semvers)
+	if found != nil || err != nil {
+		return found, err
+	}
+
+	// look for best non semver
+	nonEmpty := removeEmpty(nonSemVer)
+	if len(nonEmpty) == 1 {
+		found, err := firstWithSameDigest(nonEmpty)
+		if found != nil || err != nil {
+			return found, err
+		}
+	}
+
+	nonLatest := removeLatest(nonEmpty)
+	if len(nonLatest) == 1 {
+		found, err := firstWithSameDigest(nonLatest)
+		if found != nil || err != nil {
+			return found, err
+		}
+	}
+
+	if len(nonLatest) > 1 {
+		if log != nil {
+			log.Warn("Didn't find semantic versioning tags, still trying to choose best tag but your mileage might vary")
+		}
+
+		longest := filterNonLongest(nonLatest)
+
+		// alphabetic
+		sort.Slice(longest, func(i, j int) bool {
+			a := longest[i]
+			b := longest[j]
+			return strings.Compare(a.Tag(), b.Tag()) > 0
+		})
+
+		found, err := firstWithSameDigest(longest)
+		if found != nil || err != nil {
+			return found, err
+		}
+	}
+
+	ref, e := ref.WithRequestedFormat(FormatFull)
+	if e != nil {
+		return nil, e
+	}
+
+	return nil, errors.Errorf("Couldn't find the most precise tag for %s", ref.Formatted())
+}
+
+func filterNonLongest(nonLatest []Reference) []Reference {
+	sort.Slice(nonLatest, func(i, j int) bool {
+		a := nonLatest[i]
+		b := nonLatest[j]
+
+		return len(a.Tag()) > len(b.Tag())
+	})
+	maxLen := len(nonLatest[0].Tag())
+	longest := make([]Reference, 0)
+	for _, r := range nonLatest {
+		if len(r.Tag()) == maxLen {
+			longest = append(longest, r)
+		}
+	}
+	return longest
+}
+
+func removeLatest(refs []Reference) []Reference {
+	nonLatest := make([]Reference, 0)
+	for _, r := range refs {
+		if r.Tag() == "latest" {
+			continue
+		}
+		nonLatest = append(nonLatest, r)
+	}
+	return nonLatest
+}
+
+func removeEmpty(refs []Reference) []Reference {
+	nonEmpty := make([]Reference, 0)
+	for _, r := range refs {
+		if r.Tag() == "" {
+			continue
+		}
+		nonEmpty = append(nonEmpty, r)
+	}
+	return nonEmpty
+}
+
+// orderedSemVers splits refs into references without and with a version tag.
+// The references with version tag are ordered by descending version, then by descending precision.
+func orderedSemVers(refs []Reference) ([]Reference, []Reference) {
+	type SemVer struct {
+		ref       Reference
+		precision int
+		version   semver.Version
+	}
+
+	nonSemVer := make([]Reference, 0)
+	semvers := make([]SemVer, 0)
+
+	// look for semvers first, semvers wins
+	for _, r := range refs {
+		tag := r.Tag()
+		version, versionPrecision, e := parseVeryTolerant(tag)
+		if e != nil {
+			nonSemVer = append(nonSemVer, r)
+			continue
+		}
+		semvers = append(semvers, SemVer{r, versionPrecision, version})
+	}
+
+	sort.SliceStable(semvers, func(i, j int) bool {
+		a := semvers[i]
+		b := semvers[j]
+
+		if a.version.EQ(b.version) {
+			return a.precision > b.precision
+		}
+		return a.version.GT(b.version)
+	})
+
+	semrefs := make([]Reference, 0)
+	for _, sv := range semvers {
+		semrefs = append(semrefs, sv.ref)
+	}
+
+	return nonSemVer, semrefs
+}
+
+// splitVersionAndVariant splits a tag like "1.15.6-alpine-perl" into the version "1.15.6"
+// and the variant "alpine-perl". Tags without version are considered to be the variant,
+// except for "latest" and the empty tag.
+func splitVersionAndVariant(tag string) (version string, variant string) {
+	index := strings.Index(tag, "-")
+	if index >= 0 {
+		version = tag[0:index]
+		variant = tag[index+1:]
+		_, e := semver.ParseTolerant(version)
+		if e != nil {
+			version = ""
+			variant = tag
+		}
+		return
+	}
+
+	// no variant
+	_, e := semver.ParseTolerant(tag)
+	if e != nil {
+		if tag == "latest" || tag == "" {
+			version = tag
+			variant = ""
+		} else {
+			version = ""
+			variant = tag
+		}
+		return
+	}
+	version = tag
+	variant = ""
+
+	return
+}
+
+// parseVeryTolerant parses the version part of tag and returns the version and its precision,
+// i.e. the number of components of the version.
+func parseVeryTolerant(tag string) (semver.Version, int, error) {
+	version, _ := splitVersionAndVariant(tag)
+
+	parsed, e := semver.ParseTolerant(version)
+	if e != nil {
+		return parsed, 0, e
+	}
+
+	return parsed, len(versionComponents(version)), nil
+}
+
+func versionComponents(version string) []string {
+	return strings.Split(strings.TrimPrefix(version, "v"), ".")
+}
 
 func deliberatelyUnsued(err error) {
 	// noop
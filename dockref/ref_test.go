@@ -1,9 +1,12 @@
 package dockref
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -336,6 +339,13 @@ func TestDockref_Format(t *testing.T) {
 	}()
 }
 
+func TestFormat_Names(t *testing.T) {
+	assert.Equal(t, []string{}, Format(0).Names())
+	assert.Equal(t, []string{"name", "tag"}, (FormatHasName | FormatHasTag).Names())
+	assert.Equal(t, []string{"name", "tag", "domain", "digest"}, FormatFull.Names())
+	assert.Equal(t, []string{"digest"}, FormatHasDigest.Names())
+}
+
 func TestDockref_Formatted(t *testing.T) {
 	t.Run("reformatting with same format is equal", func(t *testing.T) {
 		originals := []string{
@@ -482,398 +492,330 @@ func TestMustParseAlgoDigest(t *testing.T) {
 		assert.NotNil(t, ref)
 	})
 }
+func toRefs(strs []string) []Reference {
+	refs := make([]Reference, 0)
+	for _, refStr := range strs {
+		ref := MustParse(refStr)
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+func list(ss []string) string {
+	join := strings.Join(ss, ", ")
+	return join
+}
+
+func assertSameSet(t *testing.T, expected []Reference, slice []Reference) {
+	t.Helper()
+	for _, item := range slice {
+		assert.Contains(t, expected, item)
+	}
+	for _, item := range expected {
+		assert.Contains(t, slice, item)
+	}
+}
+
+const digestA = "sha256:1e2b1cc7d366650a93620ca3cc8691338ed600ababf90a0e5803e1ee32486624"
+const digestB = "sha256:3d4d88675636f0fdf7899e3d3c6f8d5a9cae768e8b7f38f05505d6a88497e7a1"
+
+// failingResolver is used when all references already contain digests
+func failingResolver(r Reference) (Reference, error) {
+	return nil, errors.Errorf("unexpected resolve of %s", r.Original())
+}
+
+func TestMostPreciseTag(t *testing.T) {
+	type TestCase struct {
+		ref      string
+		list     []string
+		expected string
+	}
+
+	t.Run("Nil slice returns error", func(t *testing.T) {
+		result, err := MostPreciseTag(MustParse("nginx@"+digestA), nil, failingResolver, nil)
+		assert.Nil(t, result)
+		assert.Error(t, err)
+	})
+	t.Run("Nil element returns error", func(t *testing.T) {
+		result, err := MostPreciseTag(MustParse("nginx@"+digestA), []Reference{nil, MustParse("nginx")}, failingResolver, nil)
+		assert.Nil(t, result)
+		assert.Error(t, err)
+	})
+	t.Run("Reference without digest returns error", func(t *testing.T) {
+		result, err := MostPreciseTag(MustParse("nginx"), []Reference{MustParse("nginx")}, failingResolver, nil)
+		assert.Nil(t, result)
+		assert.Error(t, err)
+	})
+
+	cases := []TestCase{
+		{ref: "img:latest", list: []string{"img:latest", "img:1", "img:1.0.0", "img:1.0"}, expected: "img:1.0.0"},
+		{ref: "img", list: []string{"img:latest", "img:1", "img:1.0.0", "img:1.0"}, expected: "img:1.0.0"},
+		{ref: "img:1", list: []string{"img:1", "img:1.1", "img:1.1.1", "img:2.0.0"}, expected: "img:1.1.1"},
+		{ref: "img:1.0", list: []string{"img:1", "img:1.0", "img:1.0.1", "img:1.1.0"}, expected: "img:1.0.1"},
+		{ref: "img:1-alpine", list: []string{"img:1.2.3", "img:1-alpine", "img:1.2-alpine", "img:1.2.3-alpine"}, expected: "img:1.2.3-alpine"},
+		{ref: "img:latest", list: []string{"img:latest", "img:notlatest"}, expected: "img:notlatest"},
+		{ref: "img:latest", list: []string{"img:latest"}, expected: "img:latest"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.ref+" in "+list(c.list)+" to "+c.expected, func(t *testing.T) {
+			refs := make([]Reference, 0)
+			for _, r := range toRefs(c.list) {
+				refs = append(refs, r.WithDigest(digestA))
+			}
+			expected := MustParse(c.expected).WithDigest(digestA)
+
+			result, err := MostPreciseTag(MustParse(c.ref).WithDigest(digestA), refs, failingResolver, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, result)
+		})
+	}
+
+	t.Run("Skips more precise tags with other digest", func(t *testing.T) {
+		refs := []Reference{
+			MustParse("img:1").WithDigest(digestA),
+			MustParse("img:1.1").WithDigest(digestA),
+			MustParse("img:1.1.1").WithDigest(digestA),
+			MustParse("img:1.1.2").WithDigest(digestB),
+			MustParse("img:2.0.0").WithDigest(digestB),
+		}
+
+		result, err := MostPreciseTag(MustParse("img:1").WithDigest(digestA), refs, failingResolver, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.1.1", result.Tag())
+	})
+
+	t.Run("Resolves references without digest", func(t *testing.T) {
+		digests := map[string]string{
+			"1":     digestA,
+			"1.1":   digestA,
+			"1.1.1": digestA,
+			"1.1.2": digestB,
+		}
+		resolved := make([]string, 0)
+		resolver := func(r Reference) (Reference, error) {
+			resolved = append(resolved, r.Tag())
+			return r.WithDigest(digests[r.Tag()]), nil
+		}
+
+		refs := toRefs([]string{"img:1", "img:1.1", "img:1.1.1", "img:1.1.2"})
+		result, err := MostPreciseTag(MustParse("img:1").WithDigest(digestA), refs, resolver, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.1.1", result.Tag())
+		assert.Equal(t, digestA, result.DigestString())
+		assert.Equal(t, []string{"1.1.2", "1.1.1"}, resolved)
+	})
+
+	t.Run("Returns resolver errors", func(t *testing.T) {
+		expected := errors.New("expected")
+		resolver := func(r Reference) (Reference, error) {
+			return nil, expected
+		}
+
+		result, err := MostPreciseTag(MustParse("img:1").WithDigest(digestA), toRefs([]string{"img:1.1"}), resolver, nil)
+		assert.Nil(t, result)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("No matching digest returns error", func(t *testing.T) {
+		refs := []Reference{MustParse("img:1.1").WithDigest(digestB)}
+		result, err := MostPreciseTag(MustParse("img:1").WithDigest(digestA), refs, failingResolver, nil)
+		assert.Nil(t, result)
+		assert.Error(t, err)
+	})
+
+	cases = []TestCase{
+		{ref: "img", list: []string{"img:latest", "img:1", "img:1.0.0", "img:1.0"}},
+		{ref: "img", list: []string{"img:latest", "img:notlatest", "img:latest"}},
+		{ref: "img", list: []string{"img:notlatest", "img:1"}},
+	}
+
+	for _, c := range cases {
+		t.Run("Not warning for "+list(c.list), func(t *testing.T) {
+			refs := make([]Reference, 0)
+			for _, r := range toRefs(c.list) {
+				refs = append(refs, r.WithDigest(digestA))
+			}
 
-//func TestMostPreciseTag(t *testing.T) {
-//	type TestCase struct {
-//		list     []string
-//		expected string
-//	}
-//	//
-//	//t.Run("Nil slice returns nil", func(t *testing.T) {
-//	//	result, err := MostPreciseTag(nil, nil)
-//	//	assert.Nil(t, result)
-//	//	assert.Error(t, err)
-//	//})
-//	//t.Run("Nil element returns nil", func(t *testing.T) {
-//	//	result, err := MostPreciseTag([]Reference{nil, MustParse("nginx")}, nil)
-//	//	assert.Nil(t, result)
-//	//	assert.Error(t, err)
-//	//})
-//
-//	cases := []TestCase{
-//		//{list: []string{"nginx:latest"}, expected: "nginx:latest"},
-//		//{list: []string{"nginx:latest", "nginx"}, expected: "nginx:latest"},
-//		//{list: []string{"nginx", "nginx:latest"}, expected: "nginx:latest"},
-//		//{list: []string{"nginx:latest", "nginx:latest"}, expected: "nginx:latest"},
-//		//{list: []string{"nginx:latest", "nginx:notlatest", "nginx:latest"}, expected: "nginx:notlatest"},
-//		//{list: []string{"nginx:latest", "nginx:notlatest", "nginx:1", "nginx:latest"}, expected: "nginx:1"},
-//		//{list: []string{"nginx:1", "nginx:1.1"}, expected: "nginx:1.1"},
-//		//{list: []string{"nginx:1", "nginx:1.1", "nginx:2"}, expected: "nginx:2"},
-//		//{list: []string{"nginx:1", "nginx:1.1", "nginx:2", "nginx:2.1"}, expected: "nginx:2.1"},
-//		//{list: []string{"nginx:1", "nginx:1.1", "nginx:1.1-rc1"}, expected: "nginx:1.1"},
-//		//{list: []string{"nginx:1.1-rc1", "nginx:1.1-beta"}, expected: "nginx:1.1-rc1"},
-//		//{list: []string{"nginx:1.1-beta", "nginx:1.1-alpha"}, expected: "nginx:1.1-beta"},
-//		//{list: []string{"nginx:latest", "nginx:1.1-alpha"}, expected: "nginx:1.1-alpha"},
-//		//{list: []string{"img:20181120", "img:20181121", "img:20181119"}, expected: "img:20181121"},
-//		//{list: []string{"img:a", "img:aaa", "img:bb"}, expected: "img:aaa"},
-//		//{list: []string{"img:aaa", "img:aab"}, expected: "img:aab"},
-//		{list: []string{"img:latest", "img:1", "img:1.0.0", "img:1.0"}, expected: "img:1.0.0"},
-//	}
-//
-//	for _, c := range cases {
-//		t.Run(strings.Join(c.list, ", ")+" to "+c.expected, func(t *testing.T) {
-//			refs := make([]Reference, 0)
-//			for _, refStr := range c.list {
-//				ref := MustParse(refStr)
-//				refs = append(refs, ref)
-//			}
-//			expected := MustParse(c.expected)
-//			result, err := MostPreciseTag(refs, nil)
-//			assert.Equal(t, expected, result)
-//			assert.Nil(t, err)
-//		})
-//
-//	}
-//
-//	cases = []TestCase{
-//		{list: []string{"nginx:latest"}, expected: "nginx:latest"},
-//		{list: []string{"nginx:latest", "nginx"}, expected: "nginx:latest"},
-//		{list: []string{"nginx", "nginx:latest"}, expected: "nginx:latest"},
-//		{list: []string{"nginx:latest", "nginx:latest"}, expected: "nginx:latest"},
-//		{list: []string{"nginx:latest", "nginx:notlatest", "nginx:latest"}, expected: "nginx:notlatest"},
-//		{list: []string{"nginx:notlatest", "nginx:1"}, expected: "nginx:1"},
-//		{list: []string{"nginx:1", "nginx:1.1"}, expected: "nginx:1.1"},
-//	}
-//
-//	for _, c := range cases {
-//		t.Run("Not warning for "+strings.Join(c.list, ", "), func(t *testing.T) {
-//			refs := make([]Reference, 0)
-//			for _, refStr := range c.list {
-//				ref := MustParse(refStr)
-//				refs = append(refs, ref)
-//			}
-//
-//			log := logrus.New()
-//			stdout := bytes.NewBuffer(nil)
-//			log.SetOutput(stdout)
-//
-//			reference, err := MostPreciseTag(refs, log)
-//
-//			assert.NotNil(t, reference)
-//			assert.Nil(t, err)
-//
-//			str := stdout.String()
-//			assert.Empty(t, str)
-//		})
-//	}
-//
-//	cases = []TestCase{
-//		{list: []string{"img:a", "img:aaa", "img:bb"}, expected: "img:aaa"},
-//		{list: []string{"img:aaa", "img:aab"}, expected: "img:aab"},
-//	}
-//
-//	for _, c := range cases {
-//		t.Run("Warning for "+list(c.list), func(t *testing.T) {
-//			log := logrus.New()
-//			stdout := bytes.NewBuffer(nil)
-//			log.SetOutput(stdout)
-//
-//			refs := toRefs(c.list)
-//			reference, err := MostPreciseTag(refs, log)
-//
-//			assert.NotNil(t, reference)
-//			assert.Nil(t, err)
-//
-//			str := stdout.String()
-//			assert.NotEmpty(t, str)
-//		})
-//	}
-//}
-
-//func toRefs(strs []string) []Reference {
-//	refs := make([]Reference, 0)
-//	for _, refStr := range strs {
-//		ref := MustParse(refStr)
-//		refs = append(refs, ref)
-//	}
-//
-//	return refs
-//}
-
-//func TestDockref_bestSemVer(t *testing.T) {
-//	rootTestCase := t.Name()
-//
-//	expectedResults := map[string]string{
-//		"1:1.0.0:1.0":             "1.0.0",
-//		"1:1.0.0:1.0:2:2.0.0:2.0": "2.0.0",
-//		"2:2.0.0:2.0:1:1.0.0:1.0": "2.0.0",
-//	}
-//
-//	run := func(t *testing.T) {
-//		testCase := t.Name()[len(rootTestCase)+1:]
-//		expected := expectedResults[testCase]
-//
-//		reference := make([]Reference, 0)
-//		tags := strings.Split(testCase, ":")
-//		for _, tag := range tags {
-//			reference = append(reference, MustParse("img:"+tag))
-//		}
-//		_, result := orderedSemVers(reference)
-//
-//		tag := result.Tag()
-//		assert.Equal(t, expected, tag)
-//	}
-//
-//	t.Run("1:1.0.0:1.0", run)
-//	t.Run("1:1.0.0:1.0:2:2.0.0:2.0", run)
-//	t.Run("2:2.0.0:2.0:1:1.0.0:1.0", run)
-//}
-//
-//func TestDockref_TagVersionsGreaterOrEqual(t *testing.T) {
-//	rootTestCase := t.Name()
-//
-//	// format: colon separated versions
-//	// unfiltered list -> filtered list
-//	expectedResults := map[string]string{
-//		"1":               "",
-//		"2:1":             "",
-//		"2.0:1":           "",
-//		"2.0:2.0":         "2.0",
-//		"2.0:2.0:1":       "2.0",
-//		"2.0:2.0:1.1":     "2.0",
-//		"1.0:2.0:2:1.1:1": "2.0:2:1.1:1",
-//	}
-//
-//	run := func(t *testing.T) {
-//		testCase := t.Name()[len(rootTestCase)+1:]
-//		expectedStr := expectedResults[testCase]
-//
-//		reference := make([]Reference, 0)
-//		tags := strings.Split(testCase, ":")
-//		for _, tag := range tags {
-//			if tag == "" {
-//				continue
-//			}
-//			reference = append(reference, MustParse("img:"+tag))
-//		}
-//		expected := make([]Reference, 0)
-//		expectedTags := strings.Split(expectedStr, ":")
-//		for _, tag := range expectedTags {
-//			if tag == "" {
-//				continue
-//			}
-//			expected = append(expected, MustParse("img:"+tag))
-//		}
-//
-//		result, err := TagVersionsGreaterOrEqualOrNotAVersion(reference[0], reference[1:], nil)
-//		assert.Nil(t, err)
-//
-//		assertSameSet(t, expected, result)
-//	}
-//
-//	t.Run("1", run)
-//	t.Run("2:1", run)
-//	t.Run("2.0:1", run)
-//	t.Run("2.0:2.0", run)
-//	t.Run("2.0:2.0:1", run)
-//	t.Run("2.0:2.0:1.1", run)
-//	t.Run("1.0:2.0:2:1.1:1", run)
-//}
-//
-//func TestDockref_TagVersionsEqualOrNotAVersion(t *testing.T) {
-//	rootTestCase := t.Name()
-//
-//	expectedResults := map[string]string{
-//		"1":                         "",
-//		"2:1":                       "",
-//		"2.0:1":                     "",
-//		"2.0:2.0":                   "2.0",
-//		"2.0:2.0:1":                 "2.0",
-//		"2.0:2.0:1.1":               "2.0",
-//		"1.0:2.0:2:1.1:1":           "1",
-//		"1.0:2.0:2:1.1:1:1.0:1.0.0": "1:1.0:1.0.0",
-//	}
-//
-//	run := func(t *testing.T) {
-//		testCase := t.Name()[len(rootTestCase)+1:]
-//		expectedStr := expectedResults[testCase]
-//
-//		reference := make([]Reference, 0)
-//		tags := strings.Split(testCase, ":")
-//		for _, tag := range tags {
-//			if tag == "" {
-//				continue
-//			}
-//			reference = append(reference, MustParse("img:"+tag))
-//		}
-//		expected := make([]Reference, 0)
-//		expectedTags := strings.Split(expectedStr, ":")
-//		for _, tag := range expectedTags {
-//			if tag == "" {
-//				continue
-//			}
-//			expected = append(expected, MustParse("img:"+tag))
-//		}
-//
-//		result, err := TagVersionsEqualOrNotAVersion(reference[0], reference[1:], nil)
-//		assert.Nil(t, err)
-//
-//		assertSameSet(t, expected, result)
-//	}
-//
-//	t.Run("1", run)
-//	t.Run("2:1", run)
-//	t.Run("2.0:1", run)
-//	t.Run("2.0:2.0", run)
-//	t.Run("2.0:2.0:1", run)
-//	t.Run("2.0:2.0:1.1", run)
-//	t.Run("1.0:2.0:2:1.1:1", run)
-//	t.Run("1.0:2.0:2:1.1:1:1.0:1.0.0", run)
-//}
-//
-//func TestFindRelevantTagsForReference(t *testing.T) {
-//	type TestCase struct {
-//		ref      string
-//		list     []string
-//		expected []string
-//	}
-//
-//	run := func(c TestCase) func(t *testing.T) {
-//		return func(t *testing.T) {
-//			ref := MustParse(c.ref)
-//			refs := toRefs(c.list)
-//			expected := toRefs(c.expected)
-//
-//			t.Name()
-//
-//			found, e := MatchingDomainNameAndVariant(ref, refs, nil)
-//			assert.Nil(t, e)
-//
-//			assertSameSet(t, expected, found)
-//		}
-//	}
-//
-//	t.Run("empty list return empty list", run(TestCase{
-//		ref:      "img",
-//		list:     []string{},
-//		expected: []string{},
-//	}))
-//
-//	t.Run("untagged, all same name", run(TestCase{
-//		ref:      "img",
-//		list:     []string{"img:latest", "img:1", "img:2"},
-//		expected: []string{"img:latest", "img:1", "img:2"},
-//	}))
-//
-//	t.Run("latest, all same name", run(TestCase{
-//		ref:      "img:latest",
-//		list:     []string{"img:latest", "img:1.0.0", "img:2.0.0"},
-//		expected: []string{"img:latest", "img:1.0.0", "img:2.0.0"},
-//	}))
-//
-//	t.Run("different name", run(TestCase{
-//		ref:      "img",
-//		list:     []string{"other:latest", "img:latest"},
-//		expected: []string{"img:latest"},
-//	}))
-//
-//	t.Run("different domain", run(TestCase{
-//		ref:      "img",
-//		list:     []string{"example.com/img:latest", "img:latest"},
-//		expected: []string{"img:latest"},
-//	}))
-//
-//	t.Run("different other name", run(TestCase{
-//		ref:      "other",
-//		list:     []string{"other:latest", "img:latest"},
-//		expected: []string{"other:latest"},
-//	}))
-//
-//	t.Run("same name, same variant", run(TestCase{
-//		ref:      "img:1-something",
-//		list:     []string{"img:1-something", "img:1.2-something", "img:1.2.1-something", "img:2.2.1-something"},
-//		expected: []string{"img:1-something", "img:1.2-something", "img:1.2.1-something", "img:2.2.1-something"},
-//	}))
-//
-//	t.Run("same name, different variant", run(TestCase{
-//		ref:      "img:1-something",
-//		list:     []string{"img:1-something", "img:1.2-something", "img:2.2-something", "img:1.2.1-other", "img:2.2.1-other"},
-//		expected: []string{"img:1-something", "img:1.2-something", "img:2.2-something"},
-//	}))
-//
-//	t.Run("same name, different variant with common post-fix", run(TestCase{
-//		ref:      "img:1-something",
-//		list:     []string{"img:1-something", "img:1.2-something", "img:2.2-something", "img:1.2.1-other-something", "img:2.2.1-other-something"},
-//		expected: []string{"img:1-something", "img:1.2-something", "img:2.2-something"},
-//	}))
-//
-//	t.Run("same name, different variant with common pre-fix", run(TestCase{
-//		ref:      "img:1-something",
-//		list:     []string{"img:1-something", "img:1.2-something", "img:2.2-something", "img:1.2.1-something-other", "img:2.2.1-something-other"},
-//		expected: []string{"img:1-something", "img:1.2-something", "img:2.2-something"},
-//	}))
-//
-//	t.Run("unversioned tag as input returns all", run(TestCase{
-//		ref:      "img:something",
-//		list:     []string{"img:something", "img:something", "img:2.2-something", "img:something-other", "img:something-other"},
-//		expected: []string{"img:something", "img:something", "img:2.2-something"},
-//	}))
-//}
-//
-//func assertSameSet(t *testing.T, expected []Reference, slice []Reference) {
-//	t.Helper()
-//	for _, item := range slice {
-//		assert.Contains(t, expected, item)
-//	}
-//	for _, item := range expected {
-//		assert.Contains(t, slice, item)
-//	}
-//}
-//
-//func list(ss []string) string {
-//	join := strings.Join(ss, ", ")
-//	return join
-//}
-//
-//func TestSplitVersionAndVariant(t *testing.T) {
-//	t.Run("1", func(t *testing.T) {
-//		tag := "1"
-//		expVersion := "1"
-//		expVar := ""
-//		version, variant := splitVersionAndVariant(tag)
-//		assert.Equal(t, expVersion, version)
-//		assert.Equal(t, expVar, variant)
-//	})
-//	t.Run("1.1.1", func(t *testing.T) {
-//		tag := "1.1.1"
-//		expVersion := "1.1.1"
-//		expVar := ""
-//		version, variant := splitVersionAndVariant(tag)
-//		assert.Equal(t, expVersion, version)
-//		assert.Equal(t, expVar, variant)
-//	})
-//	t.Run("something", func(t *testing.T) {
-//		tag := "something"
-//		expVersion := ""
-//		expVar := "something"
-//		version, variant := splitVersionAndVariant(tag)
-//		assert.Equal(t, expVersion, version)
-//		assert.Equal(t, expVar, variant)
-//	})
-//	t.Run("1-something", func(t *testing.T) {
-//		tag := "1-something"
-//		expVersion := "1"
-//		expVar := "something"
-//		version, variant := splitVersionAndVariant(tag)
-//		assert.Equal(t, expVersion, version)
-//		assert.Equal(t, expVar, variant)
-//	})
-//	t.Run("1.15.6-alpine-perl", func(t *testing.T) {
-//		tag := "1.15.6-alpine-perl"
-//		expVersion := "1.15.6"
-//		expVar := "alpine-perl"
-//		version, variant := splitVersionAndVariant(tag)
-//		assert.Equal(t, expVersion, version)
-//		assert.Equal(t, expVar, variant)
-//	})
-//}
+			log := logrus.New()
+			stdout := bytes.NewBuffer(nil)
+			log.SetOutput(stdout)
+
+			reference, err := MostPreciseTag(MustParse(c.ref).WithDigest(digestA), refs, failingResolver, log)
+
+			assert.NotNil(t, reference)
+			assert.Nil(t, err)
+			assert.Empty(t, stdout.String())
+		})
+	}
+
+	cases = []TestCase{
+		{ref: "img", list: []string{"img:a", "img:aaa", "img:bb"}, expected: "img:aaa"},
+		{ref: "img", list: []string{"img:aaa", "img:aab"}, expected: "img:aab"},
+	}
+
+	for _, c := range cases {
+		t.Run("Warning for "+list(c.list), func(t *testing.T) {
+			refs := make([]Reference, 0)
+			for _, r := range toRefs(c.list) {
+				refs = append(refs, r.WithDigest(digestA))
+			}
+
+			log := logrus.New()
+			stdout := bytes.NewBuffer(nil)
+			log.SetOutput(stdout)
+
+			reference, err := MostPreciseTag(MustParse(c.ref).WithDigest(digestA), refs, failingResolver, log)
+
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, "img:"+reference.Tag())
+			assert.NotEmpty(t, stdout.String())
+		})
+	}
+}
+
+func TestDockref_orderedSemVers(t *testing.T) {
+	expectedResults := map[string]string{
+		"1:1.0.0:1.0":             "1.0.0:1.0:1",
+		"1:1.0.0:1.0:2:2.0.0:2.0": "2.0.0:2.0:2:1.0.0:1.0:1",
+		"2:2.0.0:2.0:1:1.0.0:1.0": "2.0.0:2.0:2:1.0.0:1.0:1",
+		"latest:1.1:edge:1.10":    "1.10:1.1",
+	}
+
+	for testCase, expected := range expectedResults {
+		t.Run(testCase, func(t *testing.T) {
+			refs := make([]Reference, 0)
+			for _, tag := range strings.Split(testCase, ":") {
+				refs = append(refs, MustParse("img:"+tag))
+			}
+
+			_, result := orderedSemVers(refs)
+
+			tags := make([]string, 0)
+			for _, r := range result {
+				tags = append(tags, r.Tag())
+			}
+			assert.Equal(t, expected, strings.Join(tags, ":"))
+		})
+	}
+}
+
+func TestTagVersionsWithinVersion(t *testing.T) {
+	// format: colon separated versions
+	// reference:unfiltered list -> filtered list
+	expectedResults := map[string]string{
+		"1":                   "",
+		"2:1":                 "",
+		"2.0:1":               "",
+		"2.0:2.0":             "2.0",
+		"2.0:2.0:2.0.1:2.1":   "2.0:2.0.1",
+		"1:1.0:2.0:2:1.1:1":   "1.0:1.1:1",
+		"latest:1:2.0:edge":   "1:2.0:edge",
+		"1-alpine:1.2-alpine": "1.2-alpine",
+	}
+
+	for testCase, expectedStr := range expectedResults {
+		t.Run(testCase, func(t *testing.T) {
+			tags := strings.Split(testCase, ":")
+			refs := make([]Reference, 0)
+			for _, tag := range tags[1:] {
+				refs = append(refs, MustParse("img:"+tag))
+			}
+
+			expected := make([]Reference, 0)
+			for _, tag := range strings.Split(expectedStr, ":") {
+				if tag == "" {
+					continue
+				}
+				expected = append(expected, MustParse("img:"+tag))
+			}
+
+			result := TagVersionsWithinVersion(MustParse("img:"+tags[0]), refs)
+
+			assertSameSet(t, expected, result)
+		})
+	}
+}
+
+func TestMatchingDomainNameAndVariant(t *testing.T) {
+	type TestCase struct {
+		ref      string
+		list     []string
+		expected []string
+	}
+
+	run := func(c TestCase) func(t *testing.T) {
+		return func(t *testing.T) {
+			ref := MustParse(c.ref)
+			refs := toRefs(c.list)
+			expected := toRefs(c.expected)
+
+			found := MatchingDomainNameAndVariant(ref, refs)
+
+			assertSameSet(t, expected, found)
+		}
+	}
+
+	t.Run("empty list return empty list", run(TestCase{
+		ref:      "img",
+		list:     []string{},
+		expected: []string{},
+	}))
+
+	t.Run("untagged, all same name", run(TestCase{
+		ref:      "img",
+		list:     []string{"img:latest", "img:1", "img:2"},
+		expected: []string{"img:latest", "img:1", "img:2"},
+	}))
+
+	t.Run("different name", run(TestCase{
+		ref:      "img",
+		list:     []string{"other:latest", "img:latest"},
+		expected: []string{"img:latest"},
+	}))
+
+	t.Run("different domain", run(TestCase{
+		ref:      "img",
+		list:     []string{"example.com/img:latest", "img:latest"},
+		expected: []string{"img:latest"},
+	}))
+
+	t.Run("same name, different variant", run(TestCase{
+		ref:      "img:1-something",
+		list:     []string{"img:1-something", "img:1.2-something", "img:2.2-something", "img:1.2.1-other", "img:2.2.1-other"},
+		expected: []string{"img:1-something", "img:1.2-something", "img:2.2-something"},
+	}))
+
+	t.Run("same name, different variant with common post-fix", run(TestCase{
+		ref:      "img:1-something",
+		list:     []string{"img:1-something", "img:1.2-something", "img:1.2.1-other-something"},
+		expected: []string{"img:1-something", "img:1.2-something"},
+	}))
+
+	t.Run("unversioned tag as input matches versioned variant", run(TestCase{
+		ref:      "img:something",
+		list:     []string{"img:something", "img:2.2-something", "img:something-other"},
+		expected: []string{"img:something", "img:2.2-something"},
+	}))
+}
+
+func TestSplitVersionAndVariant(t *testing.T) {
+	cases := map[string][]string{
+		"1":                  {"1", ""},
+		"1.1.1":              {"1.1.1", ""},
+		"something":          {"", "something"},
+		"latest":             {"latest", ""},
+		"":                   {"", ""},
+		"1-something":        {"1", "something"},
+		"1.15.6-alpine-perl": {"1.15.6", "alpine-perl"},
+	}
+
+	for tag, expected := range cases {
+		t.Run(tag, func(t *testing.T) {
+			version, variant := splitVersionAndVariant(tag)
+			assert.Equal(t, expected[0], version)
+			assert.Equal(t, expected[1], variant)
+		})
+	}
+}
@@ -15,3 +15,11 @@ const (
 type ResolverOptions struct {
 	Mode ResolveMode
 }
+
+// PlatformResolver is a Resolver that can resolve references to the manifest of a platform
+type PlatformResolver interface {
+	Resolver
+	// ResolvePlatform resolves reference to the digest of the manifest for platform.
+	// When the tag references a manifest list, the digest of the matching manifest in the list is used.
+	ResolvePlatform(reference Reference, platform Platform) (Reference, error)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/flags"
 	"github.com/docker/cli/opts"
+	distref "github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/pkg/errors"
@@ -56,7 +57,13 @@ func (reser dockerDaemonResolver) imageList(reference dockref.Reference) ([]type
 		return nil, err
 	}
 
-	summaries, err := client.ImageList(ctx, reference.Original())
+	// list all tags of the repository, not only the referenced one
+	filter := reference.Original()
+	if named := reference.Named(); named != nil {
+		filter = distref.FamiliarName(named)
+	}
+
+	summaries, err := client.ImageList(ctx, filter)
 
 	return summaries, err
 }
@@ -166,10 +173,16 @@ func (reser dockerDaemonResolver) FindAllTags(reference dockref.Reference) ([]do
 		return nil, err
 	}
 
+	// NOTE RepoTags and RepoDigests include tags and digests of other repositories referencing the same image
+	sameRepository := func(s string) bool {
+		r, e := dockref.Parse(s)
+		return e == nil && r.Name() == reference.Name()
+	}
+
 	refs := make([]dockref.Reference, 0)
 	for _, summary := range summaries {
-		digs := summary.RepoDigests
-		tags := summary.RepoTags
+		digs := filterStrings(summary.RepoDigests, sameRepository)
+		tags := filterStrings(summary.RepoTags, sameRepository)
 
 		for _, tag := range tags {
 			tagRef := dockref.MustParse(tag)
@@ -161,3 +161,35 @@ func TestDockerDaemonRegistry_Resolve_Error_in_Initialize(t *testing.T) {
 	assert.Equal(t, expected, e)
 	assert.Empty(t, references)
 }
+
+func TestDockerDaemonRegistry_FindAllTags_lists_repository(t *testing.T) {
+	reser := dockerDaemonResolverNewTest()
+	mockCli := &mockDockerCli{}
+	reser.NewCli = func(in io.ReadCloser, out *bytes.Buffer, errWriter *bytes.Buffer, isTrusted bool) dockerCliInterface {
+		return mockCli
+	}
+
+	mockClient := &mockDockerAPIClient{}
+	mockCli.On("Initialize", mock.Anything).Return(nil)
+	mockCli.On("Client").Return(mockClient)
+
+	mockClient.On("ImageList", mock.Anything, "menedev/testimagea").Return([]types.ImageSummary{
+		{
+			RepoTags:    []string{"menedev/testimagea:1", "menedev/testimagea:1.0.0", "localhost:5000/menedev/testimagea:1"},
+			RepoDigests: []string{"menedev/testimagea@sha256:1e2b1cc7d366650a93620ca3cc8691338ed600ababf90a0e5803e1ee32486624", "localhost:5000/menedev/testimagea@sha256:3d4d88675636f0fdf7899e3d3c6f8d5a9cae768e8b7f38f05505d6a88497e7a1"},
+		},
+	}, nil)
+
+	references, e := reser.FindAllTags(dockref.MustParse("menedev/testimagea:1"))
+	assert.Nil(t, e)
+
+	found := make([]string, 0)
+	for _, r := range references {
+		found = append(found, r.Tag()+"@"+r.DigestString())
+	}
+
+	assert.Equal(t, []string{
+		"1@sha256:1e2b1cc7d366650a93620ca3cc8691338ed600ababf90a0e5803e1ee32486624",
+		"1.0.0@sha256:1e2b1cc7d366650a93620ca3cc8691338ed600ababf90a0e5803e1ee32486624",
+	}, found)
+}
@@ -15,12 +15,14 @@ import (
 	"github.com/docker/cli/cli/config"
 	"github.com/docker/cli/cli/config/credentials"
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/client"
 	"github.com/docker/distribution/registry/client/auth"
 	"github.com/docker/distribution/registry/client/transport"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/registry"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 )
 
@@ -34,6 +36,7 @@ func DockerRegistryResolverNew() dockref.Resolver {
 }
 
 var _ dockref.Resolver = (*dockerRegistryResolver)(nil)
+var _ dockref.PlatformResolver = (*dockerRegistryResolver)(nil)
 
 type dockerRegistryResolver struct {
 	NewCli func(in io.ReadCloser, out *bytes.Buffer, errWriter *bytes.Buffer, isTrusted bool) dockerCliInterface
@@ -70,7 +73,6 @@ func (repo *dockerRegistryResolver) FindAllTags(ref dockref.Reference) ([]dockre
 	}
 
 	refs := make([]dockref.Reference, 0)
-	println(strings)
 	for _, tag := range strings {
 		r := ref.WithTag(tag).WithDigest("")
 
@@ -81,25 +83,100 @@ func (repo *dockerRegistryResolver) FindAllTags(ref dockref.Reference) ([]dockre
 }
 
 func (repo *dockerRegistryResolver) Resolve(ref dockref.Reference) (dockref.Reference, error) {
-	ctx := context.Background()
-	tagService, err := repo.tagService(ctx, ref)
+	repository, err := repo.repository(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	dig, err := manifestDigest(context.Background(), repository, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.WithDigest(string(dig)), nil
+}
+
+func (repo *dockerRegistryResolver) ResolvePlatform(ref dockref.Reference, platform dockref.Platform) (dockref.Reference, error) {
+	repository, err := repo.repository(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolvePlatform(context.Background(), repository, ref, platform)
+}
+
+// resolvePlatform resolves ref in repository to the manifest of platform when ref references a manifest list
+func resolvePlatform(ctx context.Context, repository distribution.Repository, ref dockref.Reference, platform dockref.Platform) (dockref.Reference, error) {
+	dig, err := manifestDigest(ctx, repository, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestService, err := repository.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := manifestService.Get(ctx, dig)
 	if err != nil {
 		return nil, err
 	}
 
+	// other manifests are for a single platform
+	list, ok := manifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		return ref.WithDigest(string(dig)), nil
+	}
+
+	platformDigest, err := platformManifest(list, platform)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot resolve %s", ref.Original())
+	}
+
+	return ref.WithDigest(string(platformDigest)), nil
+}
+
+// manifestDigest returns the digest of the manifest ref references: the digest of its tag, its digest when it has
+// no tag or the digest of "latest" when it has neither
+func manifestDigest(ctx context.Context, repository distribution.Repository, ref dockref.Reference) (digest.Digest, error) {
 	tag := ref.Tag()
+	if tag == "" && ref.DigestString() != "" {
+		manifestService, err := repository.Manifests(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		exists, err := manifestService.Exists(ctx, ref.Digest())
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "", errors.Errorf("manifest %s of %s does not exist", ref.DigestString(), ref.Original())
+		}
+
+		return ref.Digest(), nil
+	}
+
 	if tag == "" {
 		tag = "latest"
 	}
 
-	descriptor, err := tagService.Get(ctx, tag)
+	descriptor, err := repository.Tags(ctx).Get(ctx, tag)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	ref = ref.WithDigest(string(descriptor.Digest))
+	return descriptor.Digest, nil
+}
 
-	return ref, nil
+// platformManifest returns the digest of the first manifest in list that matches platform
+func platformManifest(list *manifestlist.DeserializedManifestList, platform dockref.Platform) (digest.Digest, error) {
+	for _, manifest := range list.Manifests {
+		if platform.Matches(manifest.Platform.OS, manifest.Platform.Architecture, manifest.Platform.Variant) {
+			return manifest.Digest, nil
+		}
+	}
+	return "", errors.Errorf("no manifest for platform %s", platform.String())
 }
 
 func (repo *dockerRegistryResolver) defaultCredentialsStore(ref dockref.Reference) (credentials.Store, error) {
@@ -114,6 +191,16 @@ func (repo *dockerRegistryResolver) defaultCredentialsStore(ref dockref.Referenc
 }
 
 func (repo *dockerRegistryResolver) tagService(ctx context.Context, ref dockref.Reference) (distribution.TagService, error) {
+	repository, err := repo.repository(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	tagService := repository.Tags(ctx)
+	return tagService, nil
+}
+
+func (repo *dockerRegistryResolver) repository(ref dockref.Reference) (distribution.Repository, error) {
 	store, err := repo.credentialsStoreFactory(ref)
 	if err != nil {
 		return nil, err
@@ -145,13 +232,7 @@ func (repo *dockerRegistryResolver) tagService(ctx context.Context, ref dockref.
 		return nil, err
 	}
 
-	repository, err := client.NewRepository(lrr, endpoints[0].URL.String(), roundTripper)
-	if err != nil {
-		return nil, err
-	}
-
-	tagService := repository.Tags(ctx)
-	return tagService, nil
+	return client.NewRepository(lrr, endpoints[0].URL.String(), roundTripper)
 }
 
 // getHTTPTransport builds a transport for use in communicating with a registry
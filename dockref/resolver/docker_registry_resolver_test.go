@@ -13,6 +13,10 @@ import (
 	"github.com/MeneDev/dockmoor/dockref/resolver/mocks"
 	"github.com/docker/cli/cli/config/credentials"
 	types2 "github.com/docker/cli/cli/config/types"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/testcontainers/testcontainers-go"
@@ -109,6 +113,133 @@ func TestDockerRegistryResolver_Resolve_resolves_versions_to_most_exact_version(
 	withContaineredRegistry(t, "registry_test", runAll)
 }
 
+func TestPlatformManifestSelectsMatchingManifest(t *testing.T) {
+	descriptor := func(dig string, os string, architecture string, variant string) manifestlist.ManifestDescriptor {
+		return manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{MediaType: schema2.MediaTypeManifest, Digest: digest.Digest(dig)},
+			Platform:   manifestlist.PlatformSpec{OS: os, Architecture: architecture, Variant: variant},
+		}
+	}
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		descriptor("sha256:1111111111111111111111111111111111111111111111111111111111111111", "linux", "amd64", ""),
+		descriptor("sha256:2222222222222222222222222222222222222222222222222222222222222222", "linux", "arm", "v6"),
+		descriptor("sha256:3333333333333333333333333333333333333333333333333333333333333333", "linux", "arm", "v7"),
+		descriptor("sha256:4444444444444444444444444444444444444444444444444444444444444444", "linux", "arm64", "v8"),
+	})
+	assert.Nil(t, err)
+
+	testCases := map[string]string{
+		"linux/amd64":  "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"linux/arm/v7": "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+		"linux/arm64":  "sha256:4444444444444444444444444444444444444444444444444444444444444444",
+	}
+	for platformString, expected := range testCases {
+		platform, err := dockref.ParsePlatform(platformString)
+		assert.Nil(t, err)
+
+		dig, err := platformManifest(list, platform)
+		assert.Nil(t, err)
+		assert.Equal(t, digest.Digest(expected), dig, platformString)
+	}
+
+	_, err = platformManifest(list, dockref.Platform{OS: "windows", Architecture: "amd64"})
+	assert.Error(t, err)
+}
+
+type fakeRepository struct {
+	distribution.Repository
+	tags      map[string]digest.Digest
+	manifests map[digest.Digest]distribution.Manifest
+}
+
+func (r *fakeRepository) Tags(ctx context.Context) distribution.TagService {
+	return &fakeTagService{repository: r}
+}
+
+func (r *fakeRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	return &fakeManifestService{repository: r}, nil
+}
+
+type fakeTagService struct {
+	distribution.TagService
+	repository *fakeRepository
+}
+
+func (s *fakeTagService) Get(ctx context.Context, tag string) (distribution.Descriptor, error) {
+	dig, ok := s.repository.tags[tag]
+	if !ok {
+		return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tag}
+	}
+	return distribution.Descriptor{Digest: dig}, nil
+}
+
+type fakeManifestService struct {
+	distribution.ManifestService
+	repository *fakeRepository
+}
+
+func (s *fakeManifestService) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	_, ok := s.repository.manifests[dgst]
+	return ok, nil
+}
+
+func (s *fakeManifestService) Get(ctx context.Context, dgst digest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	manifest, ok := s.repository.manifests[dgst]
+	if !ok {
+		return nil, distribution.ErrManifestUnknownRevision{Revision: dgst}
+	}
+	return manifest, nil
+}
+
+func TestResolvePlatformUsesTagOrDigest(t *testing.T) {
+	const latestList = digest.Digest("sha256:1111111111111111111111111111111111111111111111111111111111111111")
+	const latestArm64 = digest.Digest("sha256:2222222222222222222222222222222222222222222222222222222222222222")
+	const oldList = digest.Digest("sha256:3333333333333333333333333333333333333333333333333333333333333333")
+	const oldArm64 = digest.Digest("sha256:4444444444444444444444444444444444444444444444444444444444444444")
+	const single = digest.Digest("sha256:5555555555555555555555555555555555555555555555555555555555555555")
+
+	list := func(arm64 digest.Digest) distribution.Manifest {
+		manifest, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{{
+			Descriptor: distribution.Descriptor{MediaType: schema2.MediaTypeManifest, Digest: arm64},
+			Platform:   manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"},
+		}})
+		assert.Nil(t, err)
+		return manifest
+	}
+
+	repository := &fakeRepository{
+		tags: map[string]digest.Digest{"latest": latestList, "1.0": oldList, "single": single},
+		manifests: map[digest.Digest]distribution.Manifest{
+			latestList: list(latestArm64),
+			oldList:    list(oldArm64),
+			single:     &schema2.DeserializedManifest{},
+		},
+	}
+	platform := dockref.Platform{OS: "linux", Architecture: "arm64"}
+
+	testCases := map[string]digest.Digest{
+		"img":                       latestArm64,
+		"img:1.0":                   oldArm64,
+		"img@" + string(oldList):    oldArm64,
+		"img:single":                single,
+		"img@" + string(single):     single,
+		"img:1.0@" + string(single): oldArm64,
+	}
+	for ref, expected := range testCases {
+		t.Run(ref, func(t *testing.T) {
+			resolved, err := resolvePlatform(context.Background(), repository, dockref.MustParse(ref), platform)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, resolved.Digest())
+			assert.Equal(t, dockref.MustParse(ref).Tag(), resolved.Tag())
+		})
+	}
+
+	t.Run("unknown digest", func(t *testing.T) {
+		_, err := resolvePlatform(context.Background(), repository, dockref.MustParse("img@"+string(latestArm64)), platform)
+		assert.Error(t, err)
+	})
+}
+
 func withContaineredRegistry(t *testing.T, containerName string, callback func(registryAddress string)) {
 	ctx := context.Background()
 	req := testcontainers.ContainerRequest{
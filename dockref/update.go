@@ -0,0 +1,89 @@
+package dockref
+
+import (
+	"github.com/blang/semver"
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+)
+
+// UpdatePolicy limits which version components may change when updating a tag
+type UpdatePolicy int
+
+const (
+	UpdatePolicyPatch UpdatePolicy = iota
+	UpdatePolicyMinor
+	UpdatePolicyMajor
+)
+
+// NewestTag finds the reference with the newest version tag in refs that may replace the tag of ref
+// according to policy. The variant (e.g. "alpine" in "1.15-alpine") and the precision of the version
+// (e.g. "1.15" stays with two components) are kept. A reference with a newer tag has no digest.
+// When there is no newer version, ref is returned unchanged, including its digest.
+func NewestTag(ref Reference, refs []Reference, policy UpdatePolicy) (Reference, error) {
+	if refs == nil {
+		return nil, errors.New("refs is nil")
+	}
+	for _, r := range refs {
+		if r == nil {
+			return nil, errors.New("refs contains nil element")
+		}
+	}
+
+	version, precision, e := parseVeryTolerant(ref.Tag())
+	if e != nil {
+		return nil, errors.Errorf("Tag of %s is not a version", ref.Original())
+	}
+
+	newestTag := ref.Tag()
+	newestVersion := version
+	for _, r := range MatchingDomainNameAndVariant(ref, refs) {
+		v, p, e := parseVeryTolerant(r.Tag())
+		if e != nil || p != precision {
+			continue
+		}
+
+		if !policyAllows(policy, version, v) {
+			continue
+		}
+
+		if v.GT(newestVersion) {
+			newestTag = r.Tag()
+			newestVersion = v
+		}
+	}
+
+	if newestTag == ref.Tag() {
+		return ref, nil
+	}
+
+	return withOnlyTag(ref, newestTag)
+}
+
+// withOnlyTag parses a new reference with the name of ref and tag, so that Original() matches the new tag
+func withOnlyTag(ref Reference, tag string) (Reference, error) {
+	name := ref.Name()
+	if named := ref.Named(); named != nil {
+		name = reference.FamiliarName(named)
+	}
+
+	return Parse(name + ":" + tag)
+}
+
+// HasVersion returns true when the tag of ref starts with a version, e.g. "1.15" or "1.15-alpine"
+func HasVersion(ref Reference) bool {
+	_, _, e := parseVeryTolerant(ref.Tag())
+	return e == nil
+}
+
+func policyAllows(policy UpdatePolicy, current, candidate semver.Version) bool {
+	switch policy {
+	case UpdatePolicyPatch:
+		return candidate.Major == current.Major && candidate.Minor == current.Minor
+	case UpdatePolicyMinor:
+		return candidate.Major == current.Major
+	case UpdatePolicyMajor:
+		return true
+	}
+
+	return false
+}
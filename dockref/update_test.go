@@ -0,0 +1,112 @@
+package dockref
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewestTag(t *testing.T) {
+	type TestCase struct {
+		ref      string
+		list     []string
+		policy   UpdatePolicy
+		expected string
+	}
+
+	all := []string{
+		"img:latest", "img:edge",
+		"img:1", "img:1.1", "img:1.1.1", "img:1.1.2", "img:1.2", "img:1.2.0",
+		"img:2", "img:2.0", "img:2.0.0",
+		"img:1.1.1-alpine", "img:1.1.3-alpine", "img:1.2-alpine", "img:1.3-alpine", "img:2.0-alpine",
+	}
+
+	cases := []TestCase{
+		{ref: "img:1.1.1", list: all, policy: UpdatePolicyPatch, expected: "img:1.1.2"},
+		{ref: "img:1.1.1", list: all, policy: UpdatePolicyMinor, expected: "img:1.2.0"},
+		{ref: "img:1.1.1", list: all, policy: UpdatePolicyMajor, expected: "img:2.0.0"},
+		{ref: "img:1.1", list: all, policy: UpdatePolicyPatch, expected: "img:1.1"},
+		{ref: "img:1.1", list: all, policy: UpdatePolicyMinor, expected: "img:1.2"},
+		{ref: "img:1", list: all, policy: UpdatePolicyMinor, expected: "img:1"},
+		{ref: "img:1", list: all, policy: UpdatePolicyMajor, expected: "img:2"},
+		{ref: "img:2.0.0", list: all, policy: UpdatePolicyMajor, expected: "img:2.0.0"},
+		{ref: "img:1.1.1-alpine", list: all, policy: UpdatePolicyMajor, expected: "img:1.1.3-alpine"},
+		{ref: "img:1.2-alpine", list: all, policy: UpdatePolicyMinor, expected: "img:1.3-alpine"},
+		{ref: "img:1.2-alpine", list: all, policy: UpdatePolicyMajor, expected: "img:2.0-alpine"},
+		{ref: "img:1.1.1", list: []string{"other:1.1.2", "example.com/img:1.1.2"}, policy: UpdatePolicyMajor, expected: "img:1.1.1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.ref+" to "+c.expected, func(t *testing.T) {
+			result, err := NewestTag(MustParse(c.ref), toRefs(c.list), c.policy)
+			assert.Nil(t, err)
+			assert.Equal(t, MustParse(c.expected).Tag(), result.Tag())
+			assert.Equal(t, "", result.DigestString())
+			assert.Equal(t, MustParse(c.expected).Name(), result.Name())
+		})
+	}
+
+	t.Run("Removes digest", func(t *testing.T) {
+		ref := MustParse("img:1.1.1").WithDigest(digestA)
+		result, err := NewestTag(ref, toRefs([]string{"img:1.1.2"}), UpdatePolicyPatch)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.1.2", result.Tag())
+		assert.Equal(t, "", result.DigestString())
+	})
+
+	t.Run("Keeps digest without newer version", func(t *testing.T) {
+		ref := MustParse("img:1.1.2").WithDigest(digestA)
+		result, err := NewestTag(ref, toRefs([]string{"img:1.1.1", "img:1.1.2"}), UpdatePolicyPatch)
+		assert.Nil(t, err)
+		assert.Equal(t, ref, result)
+	})
+
+	t.Run("Tag without version returns error", func(t *testing.T) {
+		for _, ref := range []string{"img", "img:latest", "img:edge"} {
+			result, err := NewestTag(MustParse(ref), toRefs(all), UpdatePolicyMajor)
+			assert.Nil(t, result)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("Nil slice returns error", func(t *testing.T) {
+		result, err := NewestTag(MustParse("img:1"), nil, UpdatePolicyMajor)
+		assert.Nil(t, result)
+		assert.Error(t, err)
+	})
+
+	t.Run("Nil element returns error", func(t *testing.T) {
+		result, err := NewestTag(MustParse("img:1"), []Reference{nil, MustParse("img:2")}, UpdatePolicyMajor)
+		assert.Nil(t, result)
+		assert.Error(t, err)
+	})
+}
+
+func TestHasVersion(t *testing.T) {
+	cases := map[string]bool{
+		"img":             false,
+		"img:latest":      false,
+		"img:edge":        false,
+		"img:1":           true,
+		"img:1.15.6":      true,
+		"img:1.15-alpine": true,
+	}
+
+	for ref, expected := range cases {
+		t.Run(ref, func(t *testing.T) {
+			assert.Equal(t, expected, HasVersion(MustParse(ref)))
+		})
+	}
+}
+
+func TestNewestTagOriginalMatchesNewTag(t *testing.T) {
+	for _, ref := range []string{"img:1.1.1", "menedev/img:1.1.1", "example.com/img:1.1.1"} {
+		t.Run(ref, func(t *testing.T) {
+			r := MustParse(ref)
+			result, err := NewestTag(r, []Reference{r.WithTag("1.2.0")}, UpdatePolicyMinor)
+			assert.Nil(t, err)
+			assert.Equal(t, strings.Replace(ref, "1.1.1", "1.2.0", 1), result.Original())
+		})
+	}
+}
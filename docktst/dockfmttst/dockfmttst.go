@@ -0,0 +1,29 @@
+package dockfmttst
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// Process validates file with format, calls imageNameProcessor for each image reference and returns the written content
+func Process(t *testing.T, format dockfmt.Format, log logrus.FieldLogger, file string, imageNameProcessor dockfmt.ImageNameProcessor) (string, error) {
+	return ProcessLocated(t, format, log, file, func(r dockref.Reference, location dockfmt.Location) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+// ProcessLocated is Process with the location of the image references
+func ProcessLocated(t *testing.T, format dockfmt.Format, log logrus.FieldLogger, file string, imageNameProcessor dockfmt.LocatedImageNameProcessor) (string, error) {
+	document, err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = document.Process(log, buffer, imageNameProcessor)
+	return buffer.String(), err
+}
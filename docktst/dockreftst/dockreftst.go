@@ -38,3 +38,25 @@ func (m *MockResolver) OnResolve(reference interface{}) *mock.Call {
 func MockResolverNew() *MockResolver {
 	return &MockResolver{}
 }
+
+var _ dockref.PlatformResolver = (*MockPlatformResolver)(nil)
+
+type MockPlatformResolver struct {
+	MockResolver
+}
+
+func (m *MockPlatformResolver) ResolvePlatform(reference dockref.Reference, platform dockref.Platform) (dockref.Reference, error) {
+	called := m.Called(reference, platform)
+	i := called.Get(0)
+	ref := i.(dockref.Reference)
+	e := called.Error(1)
+	return ref, e
+}
+
+func (m *MockPlatformResolver) OnResolvePlatform(reference interface{}, platform interface{}) *mock.Call {
+	return m.On("ResolvePlatform", reference, platform)
+}
+
+func MockPlatformResolverNew() *MockPlatformResolver {
+	return &MockPlatformResolver{}
+}